@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"go.ytsaurus.tech/yt/go/yt"
+	"go.ytsaurus.tech/yt/go/yterrors"
+)
+
+const defaultACLInheritanceMode = "object_and_descendants"
+
+var aclInheritanceModes = NewStringSetFromItems(
+	defaultACLInheritanceMode,
+	"object_only",
+	"descendants_only",
+	"immediate_descendants_only",
+)
+
+func validateACLGrants(grants []ACLGrantConfig) error {
+	for idx, grant := range grants {
+		if (grant.Group == "") == (grant.SourceGroupID == "") {
+			return errors.Errorf("acl grant #%d: one and only one of group and source_group_id should be specified", idx)
+		}
+		if grant.Path == "" {
+			return errors.Errorf("acl grant #%d: path is required", idx)
+		}
+		if len(grant.Permissions) == 0 {
+			return errors.Errorf("acl grant #%d: permissions are required", idx)
+		}
+		if grant.Inheritance != "" && !aclInheritanceModes.Contains(grant.Inheritance) {
+			return errors.Errorf("acl grant #%d: unknown inheritance mode %q", idx, grant.Inheritance)
+		}
+	}
+	return nil
+}
+
+// syncACLGrants reconciles ACEs of the configured grants for managed groups by their source ids.
+// ACEs owned by the app are recorded on the node and paths of such nodes are recorded in the acl grants state,
+// so grants removed from the config (or granted to groups, which are not managed anymore) are revoked,
+// while manually added ACEs are kept.
+func (a *App) syncACLGrants(groupnamesByID map[ObjectID]string) error {
+	ownedPaths, err := a.ytsaurus.GetOwnedACLPaths()
+	if err != nil {
+		return errors.Wrap(err, "failed to get owned acl paths")
+	}
+	if len(a.aclGrants) == 0 && len(ownedPaths) == 0 {
+		return nil
+	}
+	a.logger.Info("Start syncing acl grants")
+	sourceGroupIDsByName := make(map[string]ObjectID)
+	for sourceGroupID, groupname := range groupnamesByID {
+		sourceGroupIDsByName[groupname] = sourceGroupID
+	}
+
+	// Paths are reconciled in the config order, paths removed from the config are reconciled to no grants.
+	var paths []string
+	desiredACLs := make(map[string][]ownedACE)
+	skippedCount := 0
+	for _, grant := range a.aclGrants {
+		if _, ok := desiredACLs[grant.Path]; !ok {
+			paths = append(paths, grant.Path)
+			desiredACLs[grant.Path] = nil
+		}
+		sourceGroupID, ok := sourceGroupIDsByName[grant.Group]
+		if grant.SourceGroupID != "" {
+			_, ok = groupnamesByID[grant.SourceGroupID]
+			sourceGroupID = grant.SourceGroupID
+		}
+		if !ok {
+			skippedCount++
+			a.logger.Warnw("ACL grant is skipped, its group is not managed", "grant", grant)
+			continue
+		}
+		desiredACLs[grant.Path] = append(desiredACLs[grant.Path], ownedACE{
+			SourceGroupID: sourceGroupID,
+			ACE:           buildGrantACE(groupnamesByID[sourceGroupID], grant),
+		})
+	}
+	configPathsCount := len(paths)
+	for _, path := range ownedPaths {
+		if _, ok := desiredACLs[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	// Paths are recorded before ACEs are added, so they are never lost.
+	if len(paths) > len(ownedPaths) {
+		if err = a.ytsaurus.SetOwnedACLPaths(paths); err != nil {
+			return errors.Wrap(err, "failed to record owned acl paths")
+		}
+	}
+
+	newOwnedPaths := paths[:configPathsCount:configPathsCount]
+	var updatedCount, revokedPathsCount, errCount int
+	for idx, path := range paths {
+		isConfigPath := idx < configPathsCount
+		updated, err := a.reconcileACL(path, desiredACLs[path], groupnamesByID)
+		if !isConfigPath && yterrors.ContainsResolveError(err) {
+			revokedPathsCount++
+			a.logger.Infow("Node with owned acl doesn't exist anymore", "path", path)
+			continue
+		}
+		if err != nil {
+			errCount++
+			a.logger.Errorw("failed to reconcile acl", zap.Error(err), "path", path)
+			if !isConfigPath {
+				newOwnedPaths = append(newOwnedPaths, path)
+			}
+			continue
+		}
+		if updated {
+			updatedCount++
+		}
+		if !isConfigPath {
+			revokedPathsCount++
+		}
+	}
+	// Recorded paths are the same as paths at this point, so they are changed only if some paths are revoked.
+	if len(newOwnedPaths) != len(paths) {
+		if err = a.ytsaurus.SetOwnedACLPaths(newOwnedPaths); err != nil {
+			a.logger.Errorw("failed to record owned acl paths", zap.Error(err))
+		}
+	}
+	a.logger.Infow("Finish syncing acl grants",
+		"paths", configPathsCount,
+		"updated", updatedCount,
+		"revoked_paths", revokedPathsCount,
+		"errors", errCount,
+		"skipped_grants", skippedCount,
+	)
+	return nil
+}
+
+// reconcileACL replaces owned ACEs of the node with the desired ones and returns true if ACL was changed.
+// Owned ACEs are matched by the current names of their groups, since @acl follows group renames.
+func (a *App) reconcileACL(path string, desired []ownedACE, groupnamesByID map[ObjectID]string) (bool, error) {
+	acl, owned, err := a.ytsaurus.GetACL(path)
+	if err != nil {
+		return false, err
+	}
+	newACL := append(subtractACEs(acl, resolveOwnedACEs(owned, groupnamesByID)), resolveOwnedACEs(desired, nil)...)
+	if equalACEs(acl, newACL) && equalOwnedACEs(owned, desired) {
+		return false, nil
+	}
+	a.logger.Infow("Going to update acl", "path", path, "old_acl", acl, "new_acl", newACL)
+	return true, a.ytsaurus.SetACL(path, newACL, desired)
+}
+
+func buildGrantACE(groupname string, grant ACLGrantConfig) yt.ACE {
+	inheritance := grant.Inheritance
+	if inheritance == "" {
+		inheritance = defaultACLInheritanceMode
+	}
+	return yt.ACE{
+		Action:          yt.ActionAllow,
+		Subjects:        []string{groupname},
+		Permissions:     grant.Permissions,
+		InheritanceMode: inheritance,
+	}
+}
+
+// resolveOwnedACEs returns ACEs with subjects set to the current names of their managed groups.
+// Recorded names are kept for groups, which are not managed anymore.
+func resolveOwnedACEs(owned []ownedACE, groupnamesByID map[ObjectID]string) []yt.ACE {
+	result := make([]yt.ACE, 0, len(owned))
+	for _, entry := range owned {
+		ace := entry.ACE
+		if groupname, ok := groupnamesByID[entry.SourceGroupID]; ok {
+			ace.Subjects = []string{groupname}
+		}
+		result = append(result, ace)
+	}
+	return result
+}
+
+func equalOwnedACEs(lhs, rhs []ownedACE) bool {
+	if len(lhs) != len(rhs) {
+		return false
+	}
+	for idx := range lhs {
+		if lhs[idx].SourceGroupID != rhs[idx].SourceGroupID || aceKey(lhs[idx].ACE) != aceKey(rhs[idx].ACE) {
+			return false
+		}
+	}
+	return true
+}
+
+// subtractACEs returns ACEs of acl, which are not in toRemove (each ACE of toRemove removes one entry).
+func subtractACEs(acl, toRemove []yt.ACE) []yt.ACE {
+	counts := make(map[string]int)
+	for _, ace := range toRemove {
+		counts[aceKey(ace)]++
+	}
+	var result []yt.ACE
+	for _, ace := range acl {
+		key := aceKey(ace)
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		result = append(result, ace)
+	}
+	return result
+}
+
+func equalACEs(lhs, rhs []yt.ACE) bool {
+	if len(lhs) != len(rhs) {
+		return false
+	}
+	for idx := range lhs {
+		if aceKey(lhs[idx]) != aceKey(rhs[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+// aceKey is used for ACEs comparison, YTsaurus returns ACEs with the default inheritance mode set explicitly.
+func aceKey(ace yt.ACE) string {
+	inheritance := ace.InheritanceMode
+	if inheritance == "" {
+		inheritance = defaultACLInheritanceMode
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%s",
+		ace.Action,
+		strings.Join(ace.Subjects, ","),
+		strings.Join(ace.Permissions, ","),
+		inheritance,
+		strings.Join(ace.Columns, ","),
+	)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// builtinSubjectNames are YTsaurus system users and groups, which must never be adopted or created,
+// even if some source object name happens to match.
+var builtinSubjectNames = NewStringSetFromItems(
+	"root",
+	"guest",
+	"job",
+	"scheduler",
+	"replicator",
+	"file_cache",
+	"operations_cleaner",
+	"operations_client",
+	"tablet_cell_changelogger",
+	"tablet_cell_snapshotter",
+	"table_mount_informer",
+	"tablet_balancer",
+	"alien_cell_synchronizer",
+	"queue_agent",
+	"everyone",
+	"users",
+	"superusers",
+	"admins",
+	"admin_snapshots",
+	"replicator_users",
+	"owner",
+)
+
+// adoptionPlan is a list of unmanaged YTsaurus objects, which match source objects by computed name
+// and are going to get source attribute.
+type adoptionPlan struct {
+	users  []YtsaurusUser
+	groups []YtsaurusGroup
+}
+
+// Adopt finds unmanaged YTsaurus users and groups matching source objects and makes them managed.
+// It is used for one-time launch with --adopt flag, writes are still controlled by ytsaurus.apply_*_changes.
+func (a *App) Adopt() error {
+	sourceUsers, err := a.source.GetUsers()
+	if err != nil {
+		return errors.Wrap(err, "failed to get Source users")
+	}
+	sourceGroups, err := a.source.GetGroupsWithMembers()
+	if err != nil {
+		return errors.Wrap(err, "failed to get Source groups")
+	}
+	return a.adoptUnmanagedObjects(sourceUsers, sourceGroups)
+}
+
+func (a *App) adoptUnmanagedObjects(sourceUsers []SourceUser, sourceGroups []SourceGroupWithMembers) error {
+	a.logger.Info("Start adopting unmanaged objects")
+
+	managedUsers, unmanagedUsers, err := a.ytsaurus.getUsersSplitByManagement()
+	if err != nil {
+		return errors.Wrap(err, "failed to get YTsaurus users")
+	}
+	managedGroups, unmanagedGroups, err := a.ytsaurus.getGroupsSplitByManagement()
+	if err != nil {
+		return errors.Wrap(err, "failed to get YTsaurus groups")
+	}
+
+	plan, err := a.diffAdoption(sourceUsers, managedUsers, unmanagedUsers, sourceGroups, managedGroups, unmanagedGroups)
+	if err != nil {
+		return errors.Wrap(err, "failed to calculate adoption plan")
+	}
+	for _, user := range plan.users {
+		a.logger.Infow("Adoption plan: user", "username", user.Username, "source", user.SourceRaw)
+	}
+	for _, group := range plan.groups {
+		a.logger.Infow("Adoption plan: group", "groupname", group.Name, "source", group.SourceRaw)
+	}
+
+	var userErrCount, groupErrCount int
+	for _, user := range plan.users {
+		err = a.ytsaurus.AdoptUser(user)
+		if err != nil {
+			userErrCount++
+			a.logger.Errorw("failed to adopt user", zap.Error(err), "user", user)
+		}
+	}
+	for _, group := range plan.groups {
+		err = a.ytsaurus.AdoptGroup(group)
+		if err != nil {
+			groupErrCount++
+			a.logger.Errorw("failed to adopt group", zap.Error(err), "group", group)
+		}
+	}
+	a.logger.Infow("Finish adopting unmanaged objects",
+		"users_adopted", len(plan.users)-userErrCount,
+		"users_adopt_errors", userErrCount,
+		"groups_adopted", len(plan.groups)-groupErrCount,
+		"groups_adopt_errors", groupErrCount,
+	)
+	return nil
+}
+
+// diffAdoption matches unmanaged YTsaurus users and groups with source objects by the computed name.
+// Source objects which are already managed (possibly under another name) are never adopted twice.
+func (a *App) diffAdoption(
+	sourceUsers []SourceUser,
+	managedYtUsers []YtsaurusUser,
+	unmanagedYtUsers []YtsaurusUser,
+	sourceGroups []SourceGroupWithMembers,
+	managedYtGroups []YtsaurusGroupWithMembers,
+	unmanagedYtGroups []YtsaurusGroupWithMembers,
+) (*adoptionPlan, error) {
+	plan := &adoptionPlan{}
+
+	managedUserIDs := NewStringSet()
+	for _, user := range managedYtUsers {
+		sourceUser, err := a.buildSourceUser(&user)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to build source user")
+		}
+		managedUserIDs.Add(sourceUser.GetID())
+	}
+	unmanagedUsernames := NewStringSet()
+	for _, user := range unmanagedYtUsers {
+		unmanagedUsernames.Add(user.Username)
+	}
+	for _, sourceUser := range sourceUsers {
+		if managedUserIDs.Contains(sourceUser.GetID()) {
+			continue
+		}
+		ytUser, err := a.buildYtsaurusUser(sourceUser)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to build Ytsaurus user")
+		}
+		if !unmanagedUsernames.Contains(ytUser.Username) || !a.userNamespace.contains(ytUser.Username) {
+			continue
+		}
+		plan.users = append(plan.users, ytUser)
+	}
+
+	managedGroupIDs := NewStringSet()
+	for _, group := range managedYtGroups {
+		sourceGroup, err := a.buildSourceGroup(&group)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to build source group")
+		}
+		managedGroupIDs.Add(sourceGroup.GetID())
+	}
+	unmanagedGroupnames := NewStringSet()
+	for _, group := range unmanagedYtGroups {
+		unmanagedGroupnames.Add(group.Name)
+	}
+	for _, sourceGroup := range sourceGroups {
+		// Bound groups only get membership from the source, they are never adopted.
+		if managedGroupIDs.Contains(sourceGroup.SourceGroup.GetID()) || a.isBoundSourceGroup(sourceGroup.SourceGroup.GetID()) {
+			continue
+		}
+		ytGroup, err := a.buildYtsaurusGroup(sourceGroup.SourceGroup)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to build Ytsaurus group")
+		}
+		if !unmanagedGroupnames.Contains(ytGroup.Name) || !a.groupNamespace.contains(ytGroup.Name) || a.isBoundGroup(ytGroup.Name) {
+			continue
+		}
+		plan.groups = append(plan.groups, ytGroup)
+	}
+	return plan, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffAdoption(t *testing.T) {
+	app := &App{
+		usernameReplaces:  defaultUsernameReplacements,
+		groupnameReplaces: defaultGroupnameReplacements,
+		source:            NewAzureFake(),
+		logger:            getDevelopmentLogger(),
+	}
+
+	plan, err := app.diffAdoption(
+		[]SourceUser{aliceAzure, bobAzure, carolAzure},
+		// Bob is already managed, so he can't be adopted even if YTsaurus has unmanaged user with the same name.
+		[]YtsaurusUser{bobYtsaurus},
+		[]YtsaurusUser{
+			aliceYtsaurusUnmanaged,
+			{Username: "bob"},
+			{Username: "root"},
+		},
+		[]SourceGroupWithMembers{
+			{SourceGroup: devsAzureGroup, Members: NewStringSet()},
+			{SourceGroup: hqAzureGroup, Members: NewStringSet()},
+			{SourceGroup: AzureGroup{Identity: "users", AzureID: "fake-az-users", DisplayName: "users"}, Members: NewStringSet()},
+		},
+		nil,
+		[]YtsaurusGroupWithMembers{
+			NewEmptyYtsaurusGroupWithMembers(devsYtsaurusGroupUnmanaged),
+			// Builtin group is never adopted.
+			NewEmptyYtsaurusGroupWithMembers(YtsaurusGroup{Name: "users"}),
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []YtsaurusUser{aliceYtsaurus}, plan.users)
+	require.Equal(t, []YtsaurusGroup{devsYtsaurusGroup}, plan.groups)
+}
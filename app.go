@@ -1,9 +1,8 @@
 package main
 
 import (
+	"expvar"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
@@ -20,96 +19,137 @@ type Source interface {
 	CreateGroupFromRaw(raw map[string]any) (SourceGroup, error)
 }
 
+// App syncs the source into a single YTsaurus cluster, Clusters runs it for each configured cluster.
 type App struct {
-	syncInterval      time.Duration
 	usernameReplaces  []ReplacementPair
 	groupnameReplaces []ReplacementPair
+	userNamespace     subjectNamespace
+	groupNamespace    subjectNamespace
 	removeLimit       int
 	banDuration       time.Duration
+	adoptUnmanaged    bool
+	userRenamePolicy  UserRenamePolicy
+	userAttributes    UserAttributesConfig
+
+	syncOnlyGroupMembers bool
+	extraUsers           StringSet
+	aclGrants            []ACLGrantConfig
+	unmanagedMembers     UnmanagedMembersConfig
+	groupBindings        []GroupBindingConfig
 
 	ytsaurus *Ytsaurus
 	source   Source
+	notifier Notifier
+	clock    clock.PassiveClock
+	// metrics are counters of notable sync events, see metrics.go.
+	metrics *expvar.Map
+
+	// cycleID is an ID of the current sync cycle, it is recorded in ban metadata.
+	cycleID string
 
-	stopCh chan struct{}
-	sigCh  chan os.Signal
 	logger appLoggerType
 }
 
-func NewApp(cfg *Config, logger appLoggerType) (*App, error) {
-	if cfg.Azure == nil {
-		return nil, errors.New("one and only one source should be specified")
+func validateAppConfig(cfg *AppConfig) error {
+	if err := validateUserRenamePolicy(cfg.UserRenamePolicy); err != nil {
+		return err
 	}
-
-	source, err := NewAzureReal(cfg.Azure, logger)
-	if err != nil {
-		return nil, err
+	if err := validateACLGrants(cfg.ACLGrants); err != nil {
+		return err
 	}
-
-	return NewAppCustomized(cfg, logger, source, clock.RealClock{})
-}
-
-// NewAppCustomized used in tests.
-func NewAppCustomized(cfg *Config, logger appLoggerType, source Source, clock clock.PassiveClock) (*App, error) {
-	yt, err := NewYtsaurus(&cfg.Ytsaurus, logger, clock)
-	if err != nil {
-		return nil, err
+	if err := validateUnmanagedMembersConfig(&cfg.UnmanagedMembers); err != nil {
+		return err
 	}
+	return validateGroupBindings(cfg.GroupBindings)
+}
 
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGUSR1)
-
+// newAppWithYtsaurus used in tests with YtsaurusFake client.
+func newAppWithYtsaurus(cfg *Config, logger appLoggerType, source Source, yt *Ytsaurus, clock clock.PassiveClock) *App {
 	return &App{
-		syncInterval:      cfg.App.SyncInterval,
 		usernameReplaces:  cfg.App.UsernameReplacements,
 		groupnameReplaces: cfg.App.GroupnameReplacements,
+		userNamespace:     subjectNamespace{prefix: cfg.App.Namespace.UserPrefix, suffix: cfg.App.Namespace.UserSuffix},
+		groupNamespace:    subjectNamespace{prefix: cfg.App.Namespace.GroupPrefix, suffix: cfg.App.Namespace.GroupSuffix},
 		removeLimit:       cfg.App.RemoveLimit,
 		banDuration:       cfg.App.BanBeforeRemoveDuration,
+		adoptUnmanaged:    cfg.App.AdoptUnmanaged,
+		userRenamePolicy:  cfg.App.UserRenamePolicy,
+		userAttributes:    yt.userAttributes,
+
+		syncOnlyGroupMembers: cfg.App.SyncOnlyGroupMembers,
+		extraUsers:           NewStringSetFromItems(cfg.App.ExtraUsers...),
+		aclGrants:            cfg.App.ACLGrants,
+		unmanagedMembers:     cfg.App.UnmanagedMembers,
+		groupBindings:        cfg.App.GroupBindings,
 
 		ytsaurus: yt,
 		source:   source,
+		notifier: NewNotifier(&cfg.App.Notifications, logger),
+		clock:    clock,
+		metrics:  new(expvar.Map),
 
-		stopCh: make(chan struct{}),
-		sigCh:  sigCh,
 		logger: logger,
-	}, nil
+	}
 }
 
-func (a *App) Start() {
-	a.logger.Info("Starting the application")
-	if a.syncInterval > 0 {
-		ticker := time.NewTicker(a.syncInterval)
+// runSyncLoop calls sync every interval and on SIGUSR1 until stopCh is closed.
+func runSyncLoop(interval time.Duration, stopCh chan struct{}, sigCh chan os.Signal, logger appLoggerType, sync func()) {
+	logger.Info("Starting the application")
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
 		for {
 			select {
-			case <-a.stopCh:
-				a.logger.Info("Stopping the application")
+			case <-stopCh:
+				logger.Info("Stopping the application")
 				return
 			case <-ticker.C:
-				a.logger.Debug("Received next tick")
-				a.syncOnce()
-			case <-a.sigCh:
-				a.logger.Info("Received SIGUSR1")
-				a.syncOnce()
+				logger.Debug("Received next tick")
+				sync()
+			case <-sigCh:
+				logger.Info("Received SIGUSR1")
+				sync()
 			}
 		}
 	} else {
-		a.logger.Info(
+		logger.Info(
 			"app.sync_interval config variable is not specified or is not greater than zero, " +
 				"auto sync is disabled. Send SIGUSR1 for manual sync.",
 		)
 		for {
 			select {
-			case <-a.stopCh:
-				a.logger.Info("Stopping the application")
+			case <-stopCh:
+				logger.Info("Stopping the application")
 				return
-			case <-a.sigCh:
-				a.logger.Info("Received SIGUSR1")
-				a.syncOnce()
+			case <-sigCh:
+				logger.Info("Received SIGUSR1")
+				sync()
 			}
 		}
 	}
+}
 
+// BanUsers manually bans users. Manually banned users are not reactivated by sync
+// while they are present in the source and are not scheduled for removal.
+func (a *App) BanUsers(usernames []string) error {
+	cycleID := formatAppTime(a.clock.Now())
+	for _, username := range usernames {
+		err := a.ytsaurus.BanUser(username, BanReasonManual, cycleID, time.Time{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to ban user %s", username)
+		}
+		a.logger.Infow("User banned manually", "username", username)
+	}
+	return nil
 }
 
-func (a *App) Stop() {
-	close(a.stopCh)
+// UnbanUsers lifts bans from users (for example, manually banned ones).
+func (a *App) UnbanUsers(usernames []string) error {
+	for _, username := range usernames {
+		err := a.ytsaurus.UnbanUser(username)
+		if err != nil {
+			return errors.Wrapf(err, "failed to unban user %s", username)
+		}
+		a.logger.Infow("User unbanned manually", "username", username)
+	}
+	return nil
 }
@@ -31,7 +31,7 @@ func TestAppIntegration(t *testing.T) {
 
 	logger, err := configureLogger(cfg.Logging)
 	require.NoError(t, err)
-	app, err := NewApp(cfg, logger)
+	app, err := NewClusters(cfg, logger)
 	require.NoError(t, err)
 
 	ytClient, err := ytLocal.GetClient()
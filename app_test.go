@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"os"
 	"testing"
 	"time"
 
@@ -11,6 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 	testclock "k8s.io/utils/clock/testing"
 
+	"go.ytsaurus.tech/yt/go/ypath"
 	"go.ytsaurus.tech/yt/go/yt"
 )
 
@@ -163,6 +163,17 @@ var (
 			"display_name":   bobYtsaurus.SourceRaw["display_name"],
 		},
 		BannedSince: initialTestTime,
+		BanReason:   BanReasonMissingFromSource,
+		BanCycleID:  testTimeStr,
+		RemoveAfter: initialTestTime.Add(24 * time.Hour),
+	}
+	bobYtsaurusExcluded = YtsaurusUser{
+		Username:    bobYtsaurus.Username,
+		SourceRaw:   bobYtsaurus.SourceRaw,
+		BannedSince: initialTestTime,
+		BanReason:   BanReasonExcludedByFilter,
+		BanCycleID:  testTimeStr,
+		RemoveAfter: initialTestTime.Add(24 * time.Hour),
 	}
 	carolYtsaurusBanned = YtsaurusUser{
 		Username: carolYtsaurus.Username,
@@ -217,6 +228,13 @@ var (
 		},
 	}
 
+	aliceYtsaurusUnmanaged = YtsaurusUser{
+		Username: aliceYtsaurus.Username,
+	}
+	devsYtsaurusGroupUnmanaged = YtsaurusGroup{
+		Name: devsYtsaurusGroup.Name,
+	}
+
 	defaultUsernameReplacements = []ReplacementPair{
 		{"@acme.com", ""},
 		{"@", ":"},
@@ -509,6 +527,73 @@ var (
 				},
 			},
 		},
+		{
+			name: "adopt-unmanaged-alice-and-devs",
+			appConfig: &AppConfig{
+				UsernameReplacements:  defaultUsernameReplacements,
+				GroupnameReplacements: defaultGroupnameReplacements,
+				AdoptUnmanaged:        true,
+			},
+			azureUsersSetUp: []SourceUser{
+				aliceAzure,
+			},
+			ytUsersSetUp: []YtsaurusUser{
+				aliceYtsaurusUnmanaged,
+			},
+			ytUsersExpected: []YtsaurusUser{
+				aliceYtsaurus,
+			},
+			azureGroupsSetUp: []SourceGroupWithMembers{
+				{
+					SourceGroup: devsAzureGroup,
+					Members:     NewStringSetFromItems(aliceAzure.AzureID),
+				},
+			},
+			ytGroupsSetUp: []YtsaurusGroupWithMembers{
+				NewEmptyYtsaurusGroupWithMembers(devsYtsaurusGroupUnmanaged),
+			},
+			ytGroupsExpected: []YtsaurusGroupWithMembers{
+				{
+					YtsaurusGroup: devsYtsaurusGroup,
+					Members:       NewStringSetFromItems(aliceYtsaurus.Username),
+				},
+			},
+		},
+		{
+			name: "sync-only-group-members-bob-is-excluded",
+			appConfig: &AppConfig{
+				UsernameReplacements:    defaultUsernameReplacements,
+				GroupnameReplacements:   defaultGroupnameReplacements,
+				BanBeforeRemoveDuration: 24 * time.Hour,
+				SyncOnlyGroupMembers:    true,
+				ExtraUsers:              []string{carolAzure.PrincipalName},
+			},
+			azureUsersSetUp: []SourceUser{
+				aliceAzure,
+				bobAzure,
+				carolAzure,
+			},
+			ytUsersSetUp: []YtsaurusUser{
+				bobYtsaurus,
+			},
+			ytUsersExpected: []YtsaurusUser{
+				aliceYtsaurus,
+				bobYtsaurusExcluded,
+				carolYtsaurus,
+			},
+			azureGroupsSetUp: []SourceGroupWithMembers{
+				{
+					SourceGroup: devsAzureGroup,
+					Members:     NewStringSetFromItems(aliceAzure.AzureID),
+				},
+			},
+			ytGroupsExpected: []YtsaurusGroupWithMembers{
+				{
+					YtsaurusGroup: devsYtsaurusGroup,
+					Members:       NewStringSetFromItems(aliceYtsaurus.Username),
+				},
+			},
+		},
 	}
 )
 
@@ -518,6 +603,7 @@ var (
 // [x] If Azure user already in YTsaurus with changes -> updated;
 // [x] If user in YTsaurus but not in Azure (and ban_before_remove_duration=0) -> removed;
 // [x] If user in YTsaurus but not in Azure (and ban_before_remove_duration != 0) -> banned -> removed;
+// [x] Ban reason, cycle ID and scheduled removal time are recorded for banned users;
 // [x] If Azure user without @azure attribute in YTsaurus —> ignored;
 // [x] Azure user field updates is reflected in YTsaurus user;
 // [x] YTsaurus username is built according to config;
@@ -529,9 +615,10 @@ var (
 // [x] If Azure group displayName changed -> recreate YTsaurus group;
 // [x] If Azure group displayName changed AND Azure members changed -> recreate YTsaurus group with actual members set;
 // [x] YTsaurus group name is built according to config;
-// [x] Remove limits config option works.
+// [x] Remove limits config option works;
+// [x] Unmanaged YTsaurus objects matching source objects are adopted if adopt_unmanaged is set.
+// [x] Only members of synced groups and extra users are synced if sync_only_group_members is set.
 func TestAppSync(t *testing.T) {
-	require.NoError(t, os.Setenv(defaultYtsaurusSecretEnvVar, ytDevToken))
 	for _, tc := range testCases {
 		t.Run(
 			tc.name,
@@ -540,42 +627,33 @@ func TestAppSync(t *testing.T) {
 					if tc.testTime.IsZero() {
 						tc.testTime = initialTestTime
 					}
-					clock := testclock.NewFakePassiveClock(initialTestTime)
+					clock := testclock.NewFakePassiveClock(tc.testTime)
 
-					ytLocal := NewYtsaurusLocal()
-					defer func() { require.NoError(t, ytLocal.Stop()) }()
-					require.NoError(t, ytLocal.Start())
+					ytClient := getTestYtsaurusClient(t)
 
 					azure := NewAzureFake()
 					azure.setUsers(tc.azureUsersSetUp)
 					azure.setGroups(tc.azureGroupsSetUp)
 
-					ytClient, err := ytLocal.GetClient()
-					require.NoError(t, err)
-
 					initialYtUsers, initialYtGroups := getAllYtsaurusObjects(t, ytClient)
 					setupYtsaurusObjects(t, ytClient, tc.ytUsersSetUp, tc.ytGroupsSetUp)
 
 					if tc.appConfig == nil {
 						tc.appConfig = defaultAppConfig
 					}
-					app, err := NewAppCustomized(
-						&Config{
-							App:   *tc.appConfig,
-							Azure: &AzureConfig{},
-							Ytsaurus: YtsaurusConfig{
-								Proxy:               ytLocal.GetProxy(),
-								ApplyUserChanges:    true,
-								ApplyGroupChanges:   true,
-								ApplyMemberChanges:  true,
-								LogLevel:            "DEBUG",
-								SourceAttributeName: "azure",
-							},
-						}, getDevelopmentLogger(),
-						azure,
-						clock,
-					)
-					require.NoError(t, err)
+					cfg := &Config{
+						App:   *tc.appConfig,
+						Azure: &AzureConfig{},
+						Ytsaurus: YtsaurusClustersConfig{{
+							ApplyUserChanges:    true,
+							ApplyGroupChanges:   true,
+							ApplyMemberChanges:  true,
+							SourceAttributeName: "azure",
+						}},
+					}
+					logger := getDevelopmentLogger()
+					yt := newYtsaurusWithClient(&cfg.Ytsaurus[0], ytClient, logger, clock)
+					app := newAppWithYtsaurus(cfg, logger, azure, yt, clock)
 
 					app.syncOnce()
 
@@ -607,26 +685,17 @@ func TestAppSync(t *testing.T) {
 }
 
 func TestManageUnmanagedUsersIsForbidden(t *testing.T) {
-	ytLocal := NewYtsaurusLocal()
-	defer func() { require.NoError(t, ytLocal.Stop()) }()
-	require.NoError(t, ytLocal.Start())
-
-	ytClient, err := ytLocal.GetClient()
-	require.NoError(t, err)
-
-	ytsaurus, err := NewYtsaurus(
-		&YtsaurusConfig{
-			Proxy:    ytLocal.GetProxy(),
-			LogLevel: "DEBUG",
-		},
+	ytClient := getTestYtsaurusClient(t)
+	ytsaurus := newYtsaurusWithClient(
+		&YtsaurusConfig{},
+		ytClient,
 		getDevelopmentLogger(),
 		testclock.NewFakePassiveClock(time.Now()),
 	)
-	require.NoError(t, err)
 
 	unmanagedOleg := "oleg"
 
-	err = doCreateYtsaurusUser(
+	err := doCreateYtsaurusUser(
 		context.Background(),
 		ytClient,
 		unmanagedOleg,
@@ -653,34 +722,42 @@ func TestManageUnmanagedUsersIsForbidden(t *testing.T) {
 	}
 }
 
-func getAllYtsaurusObjects(t *testing.T, client yt.Client) (users []YtsaurusUser, groups []YtsaurusGroupWithMembers) {
-	allUsers, err := doGetAllYtsaurusUsers(context.Background(), client, "azure")
+func getAllYtsaurusObjects(t *testing.T, client ytsaurusClient) (users []YtsaurusUser, groups []YtsaurusGroupWithMembers) {
+	allUsers, err := doGetAllYtsaurusUsers(context.Background(), client, "azure", UserAttributesConfig{})
 	require.NoError(t, err)
 	allGroups, err := doGetAllYtsaurusGroupsWithMembers(context.Background(), client, "azure")
 	require.NoError(t, err)
 	return allUsers, allGroups
 }
 
-func setupYtsaurusObjects(t *testing.T, client yt.Client, users []YtsaurusUser, groups []YtsaurusGroupWithMembers) {
+func setupYtsaurusObjects(t *testing.T, client ytsaurusClient, users []YtsaurusUser, groups []YtsaurusGroupWithMembers) {
 	t.Log("Setting up yt for test")
 	for _, user := range users {
 		t.Logf("creating user: %v", user)
+		attrs := buildUserAttributes(user, "azure", UserAttributesConfig{})
+		if user.IsManuallyManaged() {
+			attrs = nil
+		}
 		err := doCreateYtsaurusUser(
 			context.Background(),
 			client,
 			user.Username,
-			buildUserAttributes(user, "azure"),
+			attrs,
 		)
 		require.NoError(t, err)
 	}
 
 	for _, group := range groups {
 		t.Log("creating group:", group)
+		attrs := buildGroupAttributes(group.YtsaurusGroup, "azure")
+		if group.IsManuallyManaged() {
+			attrs = nil
+		}
 		err := doCreateYtsaurusGroup(
 			context.Background(),
 			client,
 			group.Name,
-			buildGroupAttributes(group.YtsaurusGroup, "azure"),
+			attrs,
 		)
 		require.NoError(t, err)
 		for member := range group.Members.Iter() {
@@ -695,7 +772,7 @@ func setupYtsaurusObjects(t *testing.T, client yt.Client, users []YtsaurusUser,
 	}
 }
 
-func diffYtsaurusObjects(t *testing.T, client yt.Client, expectedUsers, initialUsers []YtsaurusUser, expectedGroups, initalGroups []YtsaurusGroupWithMembers) (string, string) {
+func diffYtsaurusObjects(t *testing.T, client ytsaurusClient, expectedUsers, initialUsers []YtsaurusUser, expectedGroups, initalGroups []YtsaurusGroupWithMembers) (string, string) {
 	actualUsers, actualGroups := getAllYtsaurusObjects(t, client)
 	allExpectedUsers := append(initialUsers, expectedUsers...)
 	allExpectedGroups := append(initalGroups, expectedGroups...)
@@ -735,6 +812,34 @@ func diffYtsaurusObjects(t *testing.T, client yt.Client, expectedUsers, initialU
 	return uDiff, gDiff
 }
 
+// newTestConfig returns the config syncing fake Azure into a single YTsaurus cluster with all changes applied,
+// overrides are applied to it in order.
+func newTestConfig(overrides ...func(cfg *Config)) *Config {
+	cfg := &Config{
+		App:   *defaultAppConfig,
+		Azure: &AzureConfig{},
+		Ytsaurus: YtsaurusClustersConfig{{
+			ApplyUserChanges:    true,
+			ApplyGroupChanges:   true,
+			ApplyMemberChanges:  true,
+			SourceAttributeName: "azure",
+		}},
+	}
+	for _, override := range overrides {
+		override(cfg)
+	}
+	return cfg
+}
+
+// newTestApp creates the app for the test config with overrides, the app clock is set to initialTestTime.
+func newTestApp(ytClient ytsaurusClient, source Source, overrides ...func(cfg *Config)) *App {
+	cfg := newTestConfig(overrides...)
+	logger := getDevelopmentLogger()
+	clock := testclock.NewFakePassiveClock(initialTestTime)
+	ytsaurus := newYtsaurusWithClient(&cfg.Ytsaurus[0], ytClient, logger, clock)
+	return newAppWithYtsaurus(cfg, logger, source, ytsaurus, clock)
+}
+
 func parseAppTime(timStr string) time.Time {
 	parsed, err := time.Parse(appTimeFormat, timStr)
 	if err != nil {
@@ -742,3 +847,854 @@ func parseAppTime(timStr string) time.Time {
 	}
 	return parsed
 }
+
+func TestDiffUsersReactivation(t *testing.T) {
+	app := &App{
+		usernameReplaces:  defaultUsernameReplacements,
+		groupnameReplaces: defaultGroupnameReplacements,
+		source:            NewAzureFake(),
+		logger:            getDevelopmentLogger(),
+	}
+
+	carolYtsaurusBannedManually := carolYtsaurus
+	carolYtsaurusBannedManually.BannedSince = initialTestTime
+	carolYtsaurusBannedManually.BanReason = BanReasonManual
+
+	diff, err := app.diffUsers(
+		// Carol is banned manually, so she is not reactivated.
+		[]SourceUser{aliceAzureChangedLastName, bobAzure, carolAzure},
+		[]YtsaurusUser{aliceYtsaurus, bobYtsaurusBanned, carolYtsaurusBannedManually},
+		nil,
+	)
+	require.NoError(t, err)
+	require.Equal(t, []UpdatedYtsaurusUser{
+		{YtsaurusUser: aliceYtsaurusChangedLastName, OldUsername: aliceYtsaurus.Username},
+	}, diff.update)
+	require.Equal(t, []UpdatedYtsaurusUser{
+		{YtsaurusUser: bobYtsaurus, OldUsername: bobYtsaurus.Username},
+	}, diff.reactivate)
+	require.Empty(t, diff.create)
+	require.Empty(t, diff.remove)
+}
+
+func TestIsRemovalDue(t *testing.T) {
+	app := &App{
+		banDuration: 24 * time.Hour,
+		clock:       testclock.NewFakePassiveClock(initialTestTime.Add(30 * time.Hour)),
+	}
+	// Scheduled removal time has priority over the ban duration setting.
+	require.False(t, app.isRemovalDue(YtsaurusUser{
+		BannedSince: initialTestTime,
+		RemoveAfter: initialTestTime.Add(48 * time.Hour),
+	}))
+	require.True(t, app.isRemovalDue(YtsaurusUser{
+		BannedSince: initialTestTime.Add(20 * time.Hour),
+		RemoveAfter: initialTestTime.Add(25 * time.Hour),
+	}))
+	// Users banned before the scheduled removal time was recorded.
+	require.True(t, app.isRemovalDue(YtsaurusUser{BannedSince: initialTestTime}))
+	require.False(t, app.isRemovalDue(YtsaurusUser{BannedSince: initialTestTime.Add(20 * time.Hour)}))
+}
+
+func TestDiffUsersDisabledAccounts(t *testing.T) {
+	app := &App{
+		usernameReplaces:  defaultUsernameReplacements,
+		groupnameReplaces: defaultGroupnameReplacements,
+		source:            NewAzureFake(),
+		logger:            getDevelopmentLogger(),
+		clock:             testclock.NewFakePassiveClock(initialTestTime),
+		cycleID:           testTimeStr,
+	}
+
+	aliceAzureDisabled := aliceAzure
+	aliceAzureDisabled.AccountDisabled = true
+	bobAzureDisabled := bobAzure
+	bobAzureDisabled.AccountDisabled = true
+	carolAzureDisabled := carolAzure
+	carolAzureDisabled.AccountDisabled = true
+
+	aliceYtsaurusDisabled := aliceYtsaurus
+	aliceYtsaurusDisabled.BannedSince = initialTestTime
+	aliceYtsaurusDisabled.BanReason = BanReasonAccountDisabled
+	aliceYtsaurusDisabled.BanCycleID = testTimeStr
+	bobYtsaurusDisabled := bobYtsaurus
+	bobYtsaurusDisabled.BannedSince = initialTestTime.Add(-time.Hour)
+	bobYtsaurusDisabled.BanReason = BanReasonAccountDisabled
+
+	diff, err := app.diffUsers(
+		[]SourceUser{aliceAzureDisabled, bobAzureDisabled, carolAzureDisabled},
+		[]YtsaurusUser{aliceYtsaurus, bobYtsaurusDisabled},
+		nil,
+	)
+	require.NoError(t, err)
+	// Alice is disabled now, so she is banned without removal scheduling.
+	require.Equal(t, []UpdatedYtsaurusUser{
+		{YtsaurusUser: aliceYtsaurusDisabled, OldUsername: aliceYtsaurus.Username},
+	}, diff.disable)
+	// Bob was already banned as disabled, nothing changes, Carol is disabled and not created.
+	require.Empty(t, diff.update)
+	require.Empty(t, diff.create)
+	require.Equal(t, 1, diff.skippedDisabled)
+	require.Empty(t, diff.remove)
+
+	// Bob is enabled back and reactivated.
+	diff, err = app.diffUsers(
+		[]SourceUser{bobAzure},
+		[]YtsaurusUser{bobYtsaurusDisabled},
+		nil,
+	)
+	require.NoError(t, err)
+	require.Equal(t, []UpdatedYtsaurusUser{
+		{YtsaurusUser: bobYtsaurus, OldUsername: bobYtsaurus.Username},
+	}, diff.reactivate)
+}
+
+func TestDiffUsersRenames(t *testing.T) {
+	newApp := func(policy UserRenamePolicy) *App {
+		return &App{
+			usernameReplaces:  defaultUsernameReplacements,
+			groupnameReplaces: defaultGroupnameReplacements,
+			userRenamePolicy:  policy,
+			source:            NewAzureFake(),
+			logger:            getDevelopmentLogger(),
+		}
+	}
+	withPrincipalName := func(user AzureUser, principalName string) AzureUser {
+		user.PrincipalName = principalName
+		return user
+	}
+
+	// Bob's username is kept, but other source fields are updated.
+	diff, err := newApp(UserRenamePolicyForbid).diffUsers(
+		[]SourceUser{bobAzureChangedEmail},
+		[]YtsaurusUser{bobYtsaurus},
+		nil,
+	)
+	require.NoError(t, err)
+	bobYtsaurusNotRenamed := bobYtsaurusChangedEmail
+	bobYtsaurusNotRenamed.Username = bobYtsaurus.Username
+	require.Equal(t, []UpdatedYtsaurusUser{
+		{YtsaurusUser: bobYtsaurusNotRenamed, OldUsername: bobYtsaurus.Username},
+	}, diff.update)
+
+	// Alice takes Bob's name, which is freed in this cycle, so Alice is renamed in the next one.
+	// Carol takes Alice's name, so she is created after Alice is renamed.
+	app := newApp(UserRenamePolicyAllow)
+	diff, err = app.diffUsers(
+		[]SourceUser{
+			withPrincipalName(aliceAzure, bobAzure.PrincipalName),
+			bobAzureChangedEmail,
+			withPrincipalName(carolAzure, aliceAzure.PrincipalName),
+		},
+		[]YtsaurusUser{aliceYtsaurus, bobYtsaurus},
+		nil,
+	)
+	require.NoError(t, err)
+	require.Len(t, diff.update, 2)
+	for _, user := range diff.update {
+		if user.OldUsername == aliceYtsaurus.Username {
+			require.Equal(t, aliceYtsaurus.Username, user.Username)
+			require.Equal(t, bobAzure.PrincipalName, user.SourceRaw["principal_name"])
+		} else {
+			require.Equal(t, bobYtsaurusChangedEmail.Username, user.Username)
+		}
+	}
+	require.Empty(t, diff.create)
+	require.NotContains(t, diff.result, carolAzure.AzureID)
+	require.Equal(t, 2, diff.deferredUsernames)
+	require.Zero(t, diff.usernameCollisions)
+
+	// Next cycle Bob's name is free.
+	diff, err = app.diffUsers(
+		[]SourceUser{withPrincipalName(aliceAzure, bobAzure.PrincipalName), bobAzureChangedEmail},
+		[]YtsaurusUser{diff.update[0].YtsaurusUser, diff.update[1].YtsaurusUser},
+		nil,
+	)
+	require.NoError(t, err)
+	require.Len(t, diff.update, 1)
+	require.Equal(t, aliceYtsaurus.Username, diff.update[0].OldUsername)
+	require.Equal(t, bobYtsaurus.Username, diff.update[0].Username)
+	require.Zero(t, diff.deferredUsernames)
+
+	// Swap of usernames can't be applied.
+	diff, err = app.diffUsers(
+		[]SourceUser{
+			withPrincipalName(aliceAzure, bobAzure.PrincipalName),
+			withPrincipalName(bobAzure, aliceAzure.PrincipalName),
+		},
+		[]YtsaurusUser{aliceYtsaurus, bobYtsaurus},
+		nil,
+	)
+	require.NoError(t, err)
+	for _, user := range diff.update {
+		require.Equal(t, user.OldUsername, user.Username)
+	}
+	require.Equal(t, 2, diff.usernameCollisions)
+	require.Equal(t, aliceYtsaurus.Username, diff.result[aliceAzure.AzureID].Username)
+
+	// Carol and Dave can't be created with the same name.
+	daveAzure := withPrincipalName(carolAzure, carolAzure.PrincipalName)
+	daveAzure.AzureID = "fake-az-id-dave"
+	diff, err = app.diffUsers([]SourceUser{aliceAzure, carolAzure, daveAzure}, []YtsaurusUser{aliceYtsaurus}, nil)
+	require.NoError(t, err)
+	require.Empty(t, diff.create)
+	require.Equal(t, 2, diff.usernameCollisions)
+	require.Len(t, diff.result, 1)
+
+	// Usernames of unmanaged users are occupied too.
+	unmanagedCarol := YtsaurusUser{Username: carolYtsaurus.Username}
+	diff, err = app.diffUsers(
+		[]SourceUser{withPrincipalName(aliceAzure, carolAzure.PrincipalName), carolAzure},
+		[]YtsaurusUser{aliceYtsaurus},
+		[]YtsaurusUser{unmanagedCarol},
+	)
+	require.NoError(t, err)
+	require.Empty(t, diff.create)
+	require.Equal(t, aliceYtsaurus.Username, diff.update[0].Username)
+	require.Equal(t, 2, diff.usernameCollisions)
+	require.Zero(t, diff.deferredUsernames)
+}
+
+func TestAppSyncUsernameOfBannedUser(t *testing.T) {
+	ytClient := getTestYtsaurusClient(t)
+	setupYtsaurusObjects(t, ytClient, []YtsaurusUser{bobYtsaurus}, nil)
+
+	// Bob is missing from the source, and Carol gets his principal name.
+	carolAzureAsBob := carolAzure
+	carolAzureAsBob.PrincipalName = bobAzure.PrincipalName
+	azure := NewAzureFake()
+	azure.setUsers([]SourceUser{carolAzureAsBob})
+	app := newTestApp(ytClient, azure, func(cfg *Config) {
+		cfg.App.BanBeforeRemoveDuration = 24 * time.Hour
+	})
+	clock := app.clock.(*testclock.FakePassiveClock)
+
+	getDiff := func() *usersDiff {
+		sourceUsers, err := azure.GetUsers()
+		require.NoError(t, err)
+		ytUsers, unmanagedYtUsers, err := app.ytsaurus.getUsersSplitByManagement()
+		require.NoError(t, err)
+		diff, err := app.diffUsers(sourceUsers, ytUsers, unmanagedYtUsers)
+		require.NoError(t, err)
+		return diff
+	}
+	getBob := func() YtsaurusUser {
+		users, err := app.ytsaurus.GetUsers()
+		require.NoError(t, err)
+		for _, user := range users {
+			if user.Username == bobYtsaurus.Username {
+				return user
+			}
+		}
+		return YtsaurusUser{}
+	}
+
+	// While Bob is banned, Carol's creation is postponed every cycle without collision errors.
+	for _, elapsed := range []time.Duration{0, time.Hour, 12 * time.Hour} {
+		clock.SetTime(initialTestTime.Add(elapsed))
+		diff := getDiff()
+		require.Empty(t, diff.create)
+		require.Equal(t, 1, diff.deferredUsernames)
+		require.Zero(t, diff.usernameCollisions)
+
+		app.syncOnce()
+		bob := getBob()
+		require.True(t, bob.IsBanned())
+		require.Equal(t, bobAzure.AzureID, bob.SourceRaw["id"])
+	}
+
+	// Bob is removed after the ban, then Carol is created with the freed username.
+	clock.SetTime(initialTestTime.Add(25 * time.Hour))
+	app.syncOnce()
+	require.Eventually(t, func() bool {
+		exists, err := ytClient.NodeExists(context.Background(), ypath.Path("//sys/users/"+bobYtsaurus.Username), nil)
+		return err == nil && !exists
+	}, 3*time.Second, 300*time.Millisecond)
+	diff := getDiff()
+	require.Len(t, diff.create, 1)
+	require.Zero(t, diff.deferredUsernames)
+
+	app.syncOnce()
+	require.Equal(t, carolAzure.AzureID, getBob().SourceRaw["id"])
+}
+
+func TestAppSyncGroupAccounts(t *testing.T) {
+	ytClient := getTestYtsaurusClient(t)
+	ctx := context.Background()
+	_, err := ytClient.CreateObject(ctx, yt.NodeAccount, &yt.CreateObjectOptions{
+		Attributes: map[string]any{"name": "groups"},
+	})
+	require.NoError(t, err)
+	// The unmanaged group has the name of the source group, so the managed group can't be created.
+	require.NoError(t, doCreateYtsaurusGroup(ctx, ytClient, hqYtsaurusGroup.Name, nil))
+
+	azure := NewAzureFake()
+	azure.setGroups([]SourceGroupWithMembers{
+		{SourceGroup: devsAzureGroup, Members: NewStringSet()},
+		{SourceGroup: hqAzureGroup, Members: NewStringSet()},
+	})
+	app := newTestApp(ytClient, azure, func(cfg *Config) {
+		cfg.Ytsaurus[0].GroupAccounts = GroupAccountsConfig{
+			Enabled:        true,
+			ParentName:     "groups",
+			ResourceLimits: map[string]any{"node_count": 100},
+			OnGroupRemoval: GroupAccountRemovalPolicyRemove,
+		}
+	})
+
+	accountPath := func(name string) ypath.Path {
+		return ypath.Path("//sys/accounts").Child(name)
+	}
+	getNodeCount := func(name string) int {
+		var nodeCount int
+		require.NoError(t, ytClient.GetNode(ctx, accountPath(name).Attr("resource_limits").Child("node_count"), &nodeCount, nil))
+		return nodeCount
+	}
+
+	// Account is created with default limits and `use` is granted to the group.
+	app.syncOnce()
+	var parentName string
+	require.NoError(t, ytClient.GetNode(ctx, accountPath(devsYtsaurusGroup.Name).Attr("parent_name"), &parentName, nil))
+	require.Equal(t, "groups", parentName)
+	require.Equal(t, 100, getNodeCount(devsYtsaurusGroup.Name))
+	var acl []yt.ACE
+	require.NoError(t, ytClient.GetNode(ctx, accountPath(devsYtsaurusGroup.Name).Attr("acl"), &acl, nil))
+	require.Len(t, acl, 1)
+	require.Equal(t, []string{devsYtsaurusGroup.Name}, acl[0].Subjects)
+	require.Equal(t, []yt.Permission{yt.PermissionUse}, acl[0].Permissions)
+
+	// Account isn't created for the group, which isn't managed.
+	exists, err := ytClient.NodeExists(ctx, accountPath(hqYtsaurusGroup.Name), nil)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// Limits tuned by admins are kept, the account is renamed after the group.
+	require.NoError(t, ytClient.SetNode(ctx, accountPath(devsYtsaurusGroup.Name).Attr("resource_limits").Child("node_count"), 500, nil))
+	azure.setGroups([]SourceGroupWithMembers{{SourceGroup: devsAzureGroupChangedDisplayName, Members: NewStringSet()}})
+	app.syncOnce()
+	exists, err = ytClient.NodeExists(ctx, accountPath(devsYtsaurusGroup.Name), nil)
+	require.NoError(t, err)
+	require.False(t, exists)
+	require.Equal(t, 500, getNodeCount(devsYtsaurusGroupChangedDisplayName.Name))
+
+	// Account is removed with its group.
+	azure.setGroups(nil)
+	app.syncOnce()
+	exists, err = ytClient.NodeExists(ctx, accountPath(devsYtsaurusGroupChangedDisplayName.Name), nil)
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestAppSyncACLGrants(t *testing.T) {
+	ytClient := getTestYtsaurusClient(t)
+	ctx := context.Background()
+	const path = "//tmp/devs"
+	manualACE := yt.ACE{
+		Action:          yt.ActionAllow,
+		Subjects:        []string{"users"},
+		Permissions:     []yt.Permission{yt.PermissionRead},
+		InheritanceMode: defaultACLInheritanceMode,
+	}
+	_, err := ytClient.CreateNode(ctx, ypath.Path(path), yt.NodeMap, &yt.CreateNodeOptions{
+		Attributes: map[string]any{"acl": []yt.ACE{manualACE}},
+	})
+	require.NoError(t, err)
+
+	azure := NewAzureFake()
+	azure.setGroups([]SourceGroupWithMembers{
+		{SourceGroup: devsAzureGroup, Members: NewStringSet()},
+		{SourceGroup: hqAzureGroup, Members: NewStringSet()},
+	})
+	devsGrant := ACLGrantConfig{
+		SourceGroupID: devsAzureGroup.AzureID,
+		Path:          path,
+		Permissions:   []string{"read", "write"},
+	}
+	hqGrant := ACLGrantConfig{
+		Group:       hqYtsaurusGroup.Name,
+		Path:        path,
+		Permissions: []string{"read"},
+		Inheritance: "object_only",
+	}
+	const statePath = "//sys/ad_sync/acl_grants"
+	newApp := func(grants ...ACLGrantConfig) *App {
+		return newTestApp(ytClient, azure, func(cfg *Config) {
+			cfg.App.ACLGrants = grants
+			cfg.Ytsaurus[0].ACLGrantsStatePath = statePath
+		})
+	}
+	getACL := func() []yt.ACE {
+		var acl []yt.ACE
+		require.NoError(t, ytClient.GetNode(ctx, ypath.Path(path).Attr("acl"), &acl, nil))
+		return acl
+	}
+	groupACE := func(groupname string, grant ACLGrantConfig) yt.ACE {
+		return buildGrantACE(groupname, grant)
+	}
+
+	// Grants are added, the manual ACE is kept.
+	app := newApp(devsGrant, hqGrant)
+	app.syncOnce()
+	require.Equal(t, []yt.ACE{
+		manualACE,
+		groupACE(devsYtsaurusGroup.Name, devsGrant),
+		groupACE(hqYtsaurusGroup.Name, hqGrant),
+	}, getACL())
+
+	// Nothing is changed on the next cycle.
+	app.syncOnce()
+	require.Len(t, getACL(), 3)
+
+	// The grant follows the group rename, owned ACE of the old name is not duplicated.
+	azure.setGroups([]SourceGroupWithMembers{
+		{SourceGroup: devsAzureGroupChangedDisplayName, Members: NewStringSet()},
+		{SourceGroup: hqAzureGroup, Members: NewStringSet()},
+	})
+	app.syncOnce()
+	require.Equal(t, []yt.ACE{
+		manualACE,
+		groupACE(devsYtsaurusGroupChangedDisplayName.Name, devsGrant),
+		groupACE(hqYtsaurusGroup.Name, hqGrant),
+	}, getACL())
+
+	// Grant removed from the config is revoked.
+	newApp(devsGrant).syncOnce()
+	require.Equal(t, []yt.ACE{
+		manualACE,
+		groupACE(devsYtsaurusGroupChangedDisplayName.Name, devsGrant),
+	}, getACL())
+
+	// The group is renamed back in a cycle without acl sync, owned ACE is still matched by the source id.
+	azure.setGroups([]SourceGroupWithMembers{
+		{SourceGroup: devsAzureGroup, Members: NewStringSet()},
+		{SourceGroup: hqAzureGroup, Members: NewStringSet()},
+	})
+	app = newApp(devsGrant)
+	app.aclGrants = nil
+	app.ytsaurus.aclGrantsStatePath = ""
+	app.syncOnce()
+	newApp(devsGrant).syncOnce()
+	require.Equal(t, []yt.ACE{
+		manualACE,
+		groupACE(devsYtsaurusGroup.Name, devsGrant),
+	}, getACL())
+
+	// The last grant of the path is removed from the config, owned ACEs of the path are revoked.
+	newApp().syncOnce()
+	require.Equal(t, []yt.ACE{manualACE}, getACL())
+	var ownedPaths []string
+	require.NoError(t, ytClient.GetNode(ctx, ypath.Path(statePath), &ownedPaths, nil))
+	require.Empty(t, ownedPaths)
+
+	// Recorded path of the removed node is forgotten.
+	newApp(devsGrant).syncOnce()
+	require.NoError(t, ytClient.RemoveNode(ctx, ypath.Path(path), &yt.RemoveNodeOptions{Recursive: true}))
+	newApp().syncOnce()
+	require.NoError(t, ytClient.GetNode(ctx, ypath.Path(statePath), &ownedPaths, nil))
+	require.Empty(t, ownedPaths)
+}
+
+func TestValidateACLGrants(t *testing.T) {
+	require.NoError(t, validateACLGrants([]ACLGrantConfig{
+		{Group: "acme.devs", Path: "//home/devs", Permissions: []string{"read"}},
+	}))
+	require.Error(t, validateACLGrants([]ACLGrantConfig{
+		{Group: "acme.devs", SourceGroupID: "fake-az-acme.devs", Path: "//home/devs", Permissions: []string{"read"}},
+	}))
+	require.Error(t, validateACLGrants([]ACLGrantConfig{
+		{Group: "acme.devs", Permissions: []string{"read"}},
+	}))
+	require.Error(t, validateACLGrants([]ACLGrantConfig{
+		{Group: "acme.devs", Path: "//home/devs"},
+	}))
+	require.Error(t, validateACLGrants([]ACLGrantConfig{
+		{Group: "acme.devs", Path: "//home/devs", Permissions: []string{"read"}, Inheritance: "everything"},
+	}))
+}
+
+func TestAppSyncGroupPools(t *testing.T) {
+	ytClient := getTestYtsaurusClient(t)
+	ctx := context.Background()
+	_, err := ytClient.CreateObject(ctx, yt.NodeSchedulerPoolTree, &yt.CreateObjectOptions{
+		Attributes: map[string]any{"name": "physical"},
+	})
+	require.NoError(t, err)
+	_, err = ytClient.CreateObject(ctx, yt.NodeSchedulerPool, &yt.CreateObjectOptions{
+		Attributes: map[string]any{"name": "teams", "pool_tree": "physical"},
+	})
+	require.NoError(t, err)
+
+	azure := NewAzureFake()
+	azure.setGroups([]SourceGroupWithMembers{
+		{SourceGroup: devsAzureGroup, Members: NewStringSet()},
+		{SourceGroup: hqAzureGroup, Members: NewStringSet()},
+	})
+	app := newTestApp(ytClient, azure, func(cfg *Config) {
+		cfg.Ytsaurus[0].GroupPools = GroupPoolsConfig{
+			Enabled:          true,
+			PoolTree:         "physical",
+			ParentPoolPath:   "teams",
+			NameReplacements: []ReplacementPair{{From: ".", To: "-"}},
+			Attributes:       map[string]any{"weight": 2},
+		}
+	})
+
+	poolPath := func(name string) ypath.Path {
+		return ypath.Path("//sys/pool_trees/physical/teams").Child(name)
+	}
+	getWeight := func(name string) int {
+		var weight int
+		require.NoError(t, ytClient.GetNode(ctx, poolPath(name).Attr("weight"), &weight, nil))
+		return weight
+	}
+
+	// Pools are created with default attributes and `use` is granted to the group.
+	app.syncOnce()
+	require.Equal(t, 2, getWeight("acme-devs"))
+	require.Equal(t, 2, getWeight("acme-hq"))
+	var acl []yt.ACE
+	require.NoError(t, ytClient.GetNode(ctx, poolPath("acme-devs").Attr("acl"), &acl, nil))
+	require.Len(t, acl, 1)
+	require.Equal(t, []string{devsYtsaurusGroup.Name}, acl[0].Subjects)
+	require.Equal(t, []yt.Permission{yt.PermissionUse}, acl[0].Permissions)
+
+	// Pool of the removed group is detached with attributes tuned by admins.
+	// Group name, which can't be converted to pool name, is skipped.
+	// Pool isn't created for the group, which isn't managed: the unmanaged group has its name.
+	require.NoError(t, ytClient.SetNode(ctx, poolPath("acme-hq").Attr("weight"), 5, nil))
+	require.NoError(t, doCreateYtsaurusGroup(ctx, ytClient, "acme.it", nil))
+	azure.setGroups([]SourceGroupWithMembers{
+		{SourceGroup: devsAzureGroup, Members: NewStringSet()},
+		{SourceGroup: AzureGroup{Identity: "acme qa", AzureID: "fake-az-acme-qa", DisplayName: "acme qa"}, Members: NewStringSet()},
+		{SourceGroup: AzureGroup{Identity: "acme.it", AzureID: "fake-az-acme.it", DisplayName: "acme.it"}, Members: NewStringSet()},
+	})
+	app.syncOnce()
+	exists, err := ytClient.NodeExists(ctx, poolPath("acme-hq").Attr("azure"), nil)
+	require.NoError(t, err)
+	require.False(t, exists)
+	require.Equal(t, 5, getWeight("acme-hq"))
+	var pools []string
+	require.NoError(t, ytClient.ListNode(ctx, ypath.Path("//sys/pool_trees/physical/teams"), &pools, nil))
+	require.ElementsMatch(t, []string{"acme-devs", "acme-hq"}, pools)
+}
+
+func TestDiffGroupObjects(t *testing.T) {
+	app := newTestApp(getTestYtsaurusClient(t), NewAzureFake())
+
+	qaAzureGroup := AzureGroup{Identity: "acme.qa", AzureID: "fake-az-acme.qa", DisplayName: "acme.qa"}
+	opsAzureGroup := AzureGroup{Identity: "acme.ops", AzureID: "fake-az-acme.ops", DisplayName: "acme.ops"}
+	opsYtsaurusGroup, err := app.buildYtsaurusGroup(opsAzureGroup)
+	require.NoError(t, err)
+	devsAccount := YtsaurusGroupAccount{Name: devsYtsaurusGroup.Name, SourceRaw: devsYtsaurusGroup.SourceRaw}
+	opsAccount := YtsaurusGroupAccount{Name: opsYtsaurusGroup.Name, SourceRaw: opsYtsaurusGroup.SourceRaw}
+	syncer := &groupObjectsSyncer[YtsaurusGroupAccount]{
+		kind: "account",
+		build: func(group YtsaurusGroup) (YtsaurusGroupAccount, bool) {
+			return YtsaurusGroupAccount{Name: group.Name, SourceRaw: group.SourceRaw}, group.Name != hqYtsaurusGroup.Name
+		},
+		name:      func(account YtsaurusGroupAccount) string { return account.Name },
+		sourceRaw: func(account YtsaurusGroupAccount) map[string]any { return account.SourceRaw },
+	}
+	itAzureGroup := AzureGroup{Identity: "acme.it", AzureID: "fake-az-acme.it", DisplayName: "acme.it"}
+	sourceGroups := []SourceGroupWithMembers{
+		{SourceGroup: devsAzureGroupChangedDisplayName, Members: NewStringSet()},
+		{SourceGroup: hqAzureGroup, Members: NewStringSet()},
+		{SourceGroup: qaAzureGroup, Members: NewStringSet()},
+		{SourceGroup: itAzureGroup, Members: NewStringSet()},
+	}
+	// The group of acme.it isn't managed, e.g. its creation failed.
+	groupnamesByID := map[ObjectID]string{
+		devsAzureGroup.AzureID: devsYtsaurusGroupChangedDisplayName.Name,
+		hqAzureGroup.AzureID:   hqYtsaurusGroup.Name,
+		qaAzureGroup.AzureID:   "acme.qa",
+	}
+
+	diff, err := diffGroupObjects(app, syncer, sourceGroups, groupnamesByID, []YtsaurusGroupAccount{devsAccount, opsAccount})
+	require.NoError(t, err)
+	require.Len(t, diff.create, 1)
+	require.Equal(t, "acme.qa", diff.create[0].Name)
+	require.Len(t, diff.update, 1)
+	require.Equal(t, devsAccount.Name, diff.update[0].oldName)
+	require.Equal(t, devsYtsaurusGroupChangedDisplayName.Name, diff.update[0].object.Name)
+	require.Equal(t, []YtsaurusGroupAccount{opsAccount}, diff.remove)
+	require.Equal(t, 1, diff.invalid)
+	require.Zero(t, diff.kept)
+
+	// Objects of removed groups are kept according to the policy.
+	syncer.keepRemoved = true
+	diff, err = diffGroupObjects(app, syncer, sourceGroups, groupnamesByID, []YtsaurusGroupAccount{devsAccount, opsAccount})
+	require.NoError(t, err)
+	require.Empty(t, diff.remove)
+	require.Equal(t, 1, diff.kept)
+
+	// Object follows the actual group name, when the group rename failed.
+	groupnamesByID[devsAzureGroup.AzureID] = devsYtsaurusGroup.Name
+	diff, err = diffGroupObjects(app, syncer, sourceGroups, groupnamesByID, []YtsaurusGroupAccount{devsAccount, opsAccount})
+	require.NoError(t, err)
+	require.Empty(t, diff.update)
+}
+
+func TestAppSyncUserAttributes(t *testing.T) {
+	ytClient := getTestYtsaurusClient(t)
+	ctx := context.Background()
+
+	azure := NewAzureFake()
+	azure.setUsers([]SourceUser{aliceAzure})
+	app := newTestApp(ytClient, azure, func(cfg *Config) {
+		cfg.Ytsaurus[0].UserAttributes = UserAttributesConfig{
+			EmailAttributeName:    "email",
+			FullNameAttributeName: "full_name",
+		}
+	})
+
+	getAttribute := func(name string) string {
+		var value string
+		require.NoError(t, ytClient.GetNode(ctx, ypath.Path("//sys/users/alice").Attr(name), &value, nil))
+		return value
+	}
+
+	app.syncOnce()
+	require.Equal(t, aliceAzure.Email, getAttribute("email"))
+	require.Equal(t, aliceAzure.DisplayName, getAttribute("full_name"))
+
+	// Edits in the source are propagated.
+	aliceAzureChangedDisplayName := aliceAzure
+	aliceAzureChangedDisplayName.DisplayName = ""
+	aliceAzureChangedDisplayName.Email = "alice.henderson@acme.com"
+	azure.setUsers([]SourceUser{aliceAzureChangedDisplayName})
+	app.syncOnce()
+	require.Equal(t, "alice.henderson@acme.com", getAttribute("email"))
+	require.Equal(t, "Alice Henderson", getAttribute("full_name"))
+
+	// Nothing is updated when attributes are in sync.
+	users, err := app.ytsaurus.GetUsers()
+	require.NoError(t, err)
+	diff, err := app.diffUsers([]SourceUser{aliceAzureChangedDisplayName}, users, nil)
+	require.NoError(t, err)
+	require.Empty(t, diff.update)
+}
+
+func TestAppSyncNamespace(t *testing.T) {
+	ytClient := getTestYtsaurusClient(t)
+	ctx := context.Background()
+	_, err := ytClient.CreateObject(ctx, yt.NodeGroup, &yt.CreateObjectOptions{
+		Attributes: map[string]any{"name": "acme.hq"},
+	})
+	require.NoError(t, err)
+
+	azure := NewAzureFake()
+	azure.setUsers([]SourceUser{aliceAzure})
+	azure.setGroups([]SourceGroupWithMembers{
+		{SourceGroup: devsAzureGroup, Members: NewStringSetFromItems(aliceAzure.AzureID)},
+	})
+	newTestApp(ytClient, azure).syncOnce()
+
+	// Synced subjects are renamed into the namespace once it is configured.
+	azure.setGroups([]SourceGroupWithMembers{
+		{SourceGroup: devsAzureGroup, Members: NewStringSetFromItems(aliceAzure.AzureID)},
+		{SourceGroup: hqAzureGroup, Members: NewStringSet()},
+	})
+	app := newTestApp(ytClient, azure, func(cfg *Config) {
+		cfg.App.AdoptUnmanaged = true
+		cfg.App.Namespace = NamespaceConfig{GroupPrefix: "azure-", UserPrefix: "az-"}
+	})
+	app.syncOnce()
+	ytsaurus := app.ytsaurus
+
+	users, err := ytsaurus.GetUsers()
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	require.Equal(t, "az-alice", users[0].Username)
+
+	groups, err := ytsaurus.GetGroupsWithMembers()
+	require.NoError(t, err)
+	groupMembers := make(map[string][]string)
+	for _, group := range groups {
+		groupMembers[group.Name] = group.Members.ToSlice()
+	}
+	require.Equal(t, map[string][]string{
+		"azure-acme.devs": {"az-alice"},
+		"azure-acme.hq":   {},
+	}, groupMembers)
+
+	// The manual group with the same name is neither adopted nor changed.
+	exists, err := ytClient.NodeExists(ctx, ypath.Path("//sys/groups/acme.hq").Attr("azure"), nil)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// Names colliding with builtin subjects are refused, when they get outside the namespace.
+	adminsAzureGroup := AzureGroup{Identity: "admins", AzureID: "fake-az-admins", DisplayName: "admins"}
+	app = newTestApp(ytClient, azure, func(cfg *Config) { cfg.App.AdoptUnmanaged = true })
+	diff, err := app.diffGroups([]SourceGroupWithMembers{{SourceGroup: adminsAzureGroup, Members: NewStringSet()}}, nil, nil, NewStringSet())
+	require.NoError(t, err)
+	require.Empty(t, diff.groupsToCreate)
+	require.Equal(t, 1, diff.outsideNamespace)
+}
+
+func TestSubjectNamespaceContains(t *testing.T) {
+	namespace := subjectNamespace{prefix: "azure-", suffix: "-grp"}
+	require.Equal(t, "azure-devs-grp", namespace.apply("devs"))
+	require.True(t, namespace.contains("azure-devs-grp"))
+	require.False(t, namespace.contains("devs"))
+	require.False(t, namespace.contains("azure-devs"))
+	require.False(t, namespace.contains("azure--grp"))
+
+	require.True(t, subjectNamespace{}.contains("devs"))
+	require.False(t, subjectNamespace{}.contains("admins"))
+	require.False(t, subjectNamespace{}.contains(""))
+}
+
+func TestAppSyncUnmanagedMembers(t *testing.T) {
+	ytClient := getTestYtsaurusClient(t)
+	ctx := context.Background()
+	require.NoError(t, doCreateYtsaurusUser(ctx, ytClient, "manual", nil))
+
+	azure := NewAzureFake()
+	azure.setUsers([]SourceUser{aliceAzure})
+	azure.setGroups([]SourceGroupWithMembers{
+		{SourceGroup: devsAzureGroup, Members: NewStringSetFromItems(aliceAzure.AzureID)},
+		{SourceGroup: hqAzureGroup, Members: NewStringSetFromItems(aliceAzure.AzureID)},
+	})
+	app := newTestApp(ytClient, azure, func(cfg *Config) {
+		cfg.App.UnmanagedMembers = UnmanagedMembersConfig{
+			Policy: UnmanagedMembersPolicyReport,
+			Groups: map[string]UnmanagedMembersPolicy{hqAzureGroup.AzureID: UnmanagedMembersPolicyRemove},
+		}
+	})
+	app.syncOnce()
+
+	require.NoError(t, doAddMemberYtsaurusGroup(ctx, ytClient, "manual", "acme.devs"))
+	require.NoError(t, doAddMemberYtsaurusGroup(ctx, ytClient, "manual", "acme.hq"))
+	app.syncOnce()
+
+	groups, err := app.ytsaurus.GetGroupsWithMembers()
+	require.NoError(t, err)
+	groupMembers := make(map[string]StringSet)
+	for _, group := range groups {
+		groupMembers[group.Name] = group.Members
+	}
+	require.Equal(t, NewStringSetFromItems("alice", "manual"), groupMembers["acme.devs"])
+	require.Equal(t, NewStringSetFromItems("alice"), groupMembers["acme.hq"])
+}
+
+func TestValidateUnmanagedMembersConfig(t *testing.T) {
+	require.NoError(t, validateUnmanagedMembersConfig(&UnmanagedMembersConfig{}))
+	require.NoError(t, validateUnmanagedMembersConfig(&UnmanagedMembersConfig{
+		Policy: UnmanagedMembersPolicyRemove,
+		Groups: map[string]UnmanagedMembersPolicy{"acme.devs": UnmanagedMembersPolicyPreserve},
+	}))
+	require.Error(t, validateUnmanagedMembersConfig(&UnmanagedMembersConfig{Policy: "drop"}))
+	require.Error(t, validateUnmanagedMembersConfig(&UnmanagedMembersConfig{
+		Groups: map[string]UnmanagedMembersPolicy{"acme.devs": "drop"},
+	}))
+}
+
+func TestAppSyncGroupBindings(t *testing.T) {
+	ytClient := getTestYtsaurusClient(t)
+	ctx := context.Background()
+	require.NoError(t, doCreateYtsaurusUser(ctx, ytClient, "manual", nil))
+	require.NoError(t, doCreateYtsaurusGroup(ctx, ytClient, "legacy-devs", nil))
+	require.NoError(t, doAddMemberYtsaurusGroup(ctx, ytClient, "manual", "legacy-devs"))
+	// The group matches the computed name of the bound source group, but it is not adopted.
+	require.NoError(t, doCreateYtsaurusGroup(ctx, ytClient, "acme.devs", nil))
+
+	azure := NewAzureFake()
+	azure.setUsers([]SourceUser{aliceAzure, bobAzure})
+	azure.setGroups([]SourceGroupWithMembers{
+		{SourceGroup: devsAzureGroup, Members: NewStringSetFromItems(aliceAzure.AzureID, bobAzure.AzureID)},
+		{SourceGroup: hqAzureGroup, Members: NewStringSetFromItems(aliceAzure.AzureID)},
+	})
+	app := newTestApp(ytClient, azure, func(cfg *Config) {
+		cfg.App.AdoptUnmanaged = true
+		cfg.App.GroupBindings = []GroupBindingConfig{
+			{SourceGroupID: devsAzureGroup.AzureID, Group: "legacy-devs"},
+			{SourceGroupID: "fake-az-missing", Group: "legacy-missing"},
+		}
+	})
+	ytsaurus := app.ytsaurus
+
+	getGroupMembers := func() map[string]StringSet {
+		_, unmanagedGroups, err := ytsaurus.getGroupsSplitByManagement()
+		require.NoError(t, err)
+		managedGroups, err := ytsaurus.GetGroupsWithMembers()
+		require.NoError(t, err)
+		groupMembers := make(map[string]StringSet)
+		for _, group := range append(unmanagedGroups, managedGroups...) {
+			groupMembers[group.Name] = group.Members
+		}
+		return groupMembers
+	}
+
+	app.syncOnce()
+	managedGroups, err := ytsaurus.GetGroupsWithMembers()
+	require.NoError(t, err)
+	require.Len(t, managedGroups, 1)
+	require.Equal(t, "acme.hq", managedGroups[0].Name)
+	groupMembers := getGroupMembers()
+	require.Equal(t, NewStringSetFromItems("manual", "alice", "bob"), groupMembers["legacy-devs"])
+	require.Equal(t, NewStringSet(), groupMembers["acme.devs"])
+
+	// Membership follows the source group, manual members are preserved.
+	azure.setGroups([]SourceGroupWithMembers{
+		{SourceGroup: devsAzureGroup, Members: NewStringSetFromItems(aliceAzure.AzureID)},
+		{SourceGroup: hqAzureGroup, Members: NewStringSetFromItems(aliceAzure.AzureID)},
+	})
+	app.syncOnce()
+	require.Equal(t, NewStringSetFromItems("manual", "alice"), getGroupMembers()["legacy-devs"])
+
+	// Bound group is neither removed nor becomes managed, when the source group is gone.
+	azure.setGroups([]SourceGroupWithMembers{
+		{SourceGroup: hqAzureGroup, Members: NewStringSetFromItems(aliceAzure.AzureID)},
+	})
+	app.syncOnce()
+	require.Equal(t, NewStringSetFromItems("manual", "alice"), getGroupMembers()["legacy-devs"])
+	exists, err := ytClient.NodeExists(ctx, ypath.Path("//sys/groups/legacy-devs").Attr("azure"), nil)
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestValidateGroupBindings(t *testing.T) {
+	require.NoError(t, validateGroupBindings([]GroupBindingConfig{
+		{SourceGroupID: "fake-az-acme.devs", Group: "legacy-devs"},
+		{SourceGroupID: "fake-az-acme.hq", Group: "legacy-hq"},
+	}))
+	require.Error(t, validateGroupBindings([]GroupBindingConfig{{Group: "legacy-devs"}}))
+	require.Error(t, validateGroupBindings([]GroupBindingConfig{{SourceGroupID: "fake-az-acme.devs", Group: "superusers"}}))
+	require.Error(t, validateGroupBindings([]GroupBindingConfig{
+		{SourceGroupID: "fake-az-acme.devs", Group: "legacy-devs"},
+		{SourceGroupID: "fake-az-acme.devs", Group: "legacy-hq"},
+	}))
+	require.Error(t, validateGroupBindings([]GroupBindingConfig{
+		{SourceGroupID: "fake-az-acme.devs", Group: "legacy-devs"},
+		{SourceGroupID: "fake-az-acme.hq", Group: "legacy-devs"},
+	}))
+}
+
+func TestAppSyncReactivationMetrics(t *testing.T) {
+	ytClient := getTestYtsaurusClient(t)
+	ctx := context.Background()
+
+	azure := NewAzureFake()
+	azure.setUsers([]SourceUser{aliceAzure, bobAzure})
+	app := newTestApp(ytClient, azure, func(cfg *Config) {
+		cfg.Ytsaurus[0].MembershipsStorePath = "//tmp/memberships"
+	})
+
+	// Bob is banned and returns to the source, Alice was removed and her memberships were recorded at ban time.
+	setupYtsaurusObjects(t, ytClient, []YtsaurusUser{bobYtsaurusBanned}, nil)
+	require.NoError(t, app.ytsaurus.CreateUser(aliceYtsaurus))
+	require.NoError(t, doCreateYtsaurusGroup(ctx, ytClient, "manual", nil))
+	require.NoError(t, doAddMemberYtsaurusGroup(ctx, ytClient, aliceYtsaurus.Username, "manual"))
+	require.NoError(t, app.ytsaurus.RecordMemberships(aliceAzure.AzureID, aliceYtsaurus.Username))
+	require.NoError(t, app.ytsaurus.RemoveUser(aliceYtsaurus.Username))
+	require.Eventually(t, func() bool {
+		exists, err := ytClient.NodeExists(ctx, ypath.Path("//sys/users/"+aliceYtsaurus.Username), nil)
+		return err == nil && !exists
+	}, 3*time.Second, 300*time.Millisecond)
+
+	app.syncOnce()
+	app.syncOnce()
+	require.Equal(t, "1", app.metrics.Get(metricUsersReactivated).String())
+	require.Equal(t, "0", app.metrics.Get(metricUserReactivateErrors).String())
+	require.Equal(t, "1", app.metrics.Get(metricMembershipsRestored).String())
+}
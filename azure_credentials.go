@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/pkg/errors"
+)
+
+const (
+	azureAuthMethodClientSecret      = "client_secret"
+	azureAuthMethodClientCertificate = "client_certificate"
+	azureAuthMethodManagedIdentity   = "managed_identity"
+	azureAuthMethodWorkloadIdentity  = "workload_identity"
+
+	defaultAzureSecretEnvVar = "AZURE_CLIENT_SECRET"
+)
+
+// newAzureCredential creates Azure credential according to the configured auth method.
+// Client secret is read from the secret reader only for client_secret auth method.
+func newAzureCredential(cfg *AzureConfig, clientSecret *secretReader) (azcore.TokenCredential, error) {
+	if cfg.AuthMethod == "" {
+		cfg.AuthMethod = azureAuthMethodClientSecret
+	}
+
+	switch cfg.AuthMethod {
+	case azureAuthMethodClientSecret:
+		secret, err := clientSecret.read()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read Azure client secret")
+		}
+		cred, err := azidentity.NewClientSecretCredential(cfg.Tenant, cfg.ClientID, secret, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create Azure secret credentials")
+		}
+		return cred, nil
+
+	case azureAuthMethodClientCertificate:
+		if cfg.ClientCertificatePath == "" {
+			return nil, errors.New("client_certificate_path should be specified for client_certificate auth method")
+		}
+		certData, err := os.ReadFile(cfg.ClientCertificatePath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read Azure client certificate %s", cfg.ClientCertificatePath)
+		}
+		var password []byte
+		if cfg.ClientCertificatePasswordEnvVar != "" {
+			password = []byte(os.Getenv(cfg.ClientCertificatePasswordEnvVar))
+		}
+		// ParseCertificates handles both PEM and PFX (PKCS#12) formats.
+		certs, key, err := azidentity.ParseCertificates(certData, password)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse Azure client certificate %s", cfg.ClientCertificatePath)
+		}
+		cred, err := azidentity.NewClientCertificateCredential(cfg.Tenant, cfg.ClientID, certs, key, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create Azure certificate credentials")
+		}
+		return cred, nil
+
+	case azureAuthMethodManagedIdentity:
+		options := &azidentity.ManagedIdentityCredentialOptions{}
+		if cfg.ManagedIdentityClientID != "" {
+			options.ID = azidentity.ClientID(cfg.ManagedIdentityClientID)
+		}
+		cred, err := azidentity.NewManagedIdentityCredential(options)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create Azure managed identity credentials")
+		}
+		return cred, nil
+
+	case azureAuthMethodWorkloadIdentity:
+		// Empty fields are filled by azidentity from AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_FEDERATED_TOKEN_FILE
+		// env vars, which are set by the AKS workload identity webhook.
+		cred, err := azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			TenantID:      cfg.Tenant,
+			ClientID:      cfg.ClientID,
+			TokenFilePath: cfg.FederatedTokenFile,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create Azure workload identity credentials")
+		}
+		return cred, nil
+
+	default:
+		return nil, errors.Errorf("unknown Azure auth method %q", cfg.AuthMethod)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCertificatePEM(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ytsaurus-ad-sync-test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	content := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	content = append(content, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})...)
+	require.NoError(t, os.WriteFile(path, content, 0600))
+	return path
+}
+
+func TestNewAzureCredential(t *testing.T) {
+	const secretEnvVar = "TEST_AZURE_CLIENT_SECRET"
+	require.NoError(t, os.Setenv(secretEnvVar, "secret"))
+
+	baseConfig := AzureConfig{
+		Tenant:   "acme.onmicrosoft.com",
+		ClientID: "abcdefgh-a000-b111-c222-abcdef123456",
+	}
+
+	cfg := baseConfig
+	cred, err := newAzureCredential(&cfg, newSecretReader(secretEnvVar, ""))
+	require.NoError(t, err)
+	require.IsType(t, &azidentity.ClientSecretCredential{}, cred)
+	require.Equal(t, azureAuthMethodClientSecret, cfg.AuthMethod)
+
+	cfg = baseConfig
+	_, err = newAzureCredential(&cfg, newSecretReader("TEST_AZURE_CLIENT_SECRET_MISSING", ""))
+	require.ErrorContains(t, err, "TEST_AZURE_CLIENT_SECRET_MISSING env var shouldn't be empty")
+
+	cfg = baseConfig
+	cfg.AuthMethod = azureAuthMethodClientCertificate
+	cfg.ClientCertificatePath = writeTestCertificatePEM(t)
+	cred, err = newAzureCredential(&cfg, nil)
+	require.NoError(t, err)
+	require.IsType(t, &azidentity.ClientCertificateCredential{}, cred)
+
+	cfg = baseConfig
+	cfg.AuthMethod = azureAuthMethodClientCertificate
+	_, err = newAzureCredential(&cfg, nil)
+	require.ErrorContains(t, err, "client_certificate_path should be specified")
+
+	cfg = baseConfig
+	cfg.AuthMethod = azureAuthMethodManagedIdentity
+	cfg.ManagedIdentityClientID = "fake-managed-identity-id"
+	cred, err = newAzureCredential(&cfg, nil)
+	require.NoError(t, err)
+	require.IsType(t, &azidentity.ManagedIdentityCredential{}, cred)
+
+	cfg = baseConfig
+	cfg.AuthMethod = azureAuthMethodWorkloadIdentity
+	cfg.FederatedTokenFile = filepath.Join(t.TempDir(), "token")
+	cred, err = newAzureCredential(&cfg, nil)
+	require.NoError(t, err)
+	require.IsType(t, &azidentity.WorkloadIdentityCredential{}, cred)
+
+	cfg = baseConfig
+	cfg.AuthMethod = "password"
+	_, err = newAzureCredential(&cfg, nil)
+	require.ErrorContains(t, err, `unknown Azure auth method "password"`)
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	abstractionsauth "github.com/microsoft/kiota-abstractions-go/authentication"
+	"github.com/pkg/errors"
+)
+
+const defaultAzureGraphFakePageSize = 100
+
+var (
+	graphFakeEqRegexp         = regexp.MustCompile(`^(\w+) (eq|ne) (?:'([^']*)'|(true|false))$`)
+	graphFakeStartsWithRegexp = regexp.MustCompile(`^startswith\((\w+),\s*'([^']*)'\)$`)
+)
+
+// AzureGraphFakeServer is a local MS Graph API server for AzureReal tests.
+// It serves users, service principals, groups (with members $expand), group members,
+// administrative unit members and owned objects with @odata.nextLink paging,
+// supports a subset of $filter (eq, ne, startswith joined with "and") and $select,
+// and can respond with throttling errors to test retries.
+type AzureGraphFakeServer struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	pageSize int
+	users    []map[string]any
+	groups   []map[string]any
+	// servicePrincipals are listed separately from users, as in real MS Graph.
+	servicePrincipals []map[string]any
+	// members are ids of group members by group id.
+	members map[string][]string
+	// administrativeUnitMembers are ids of administrative unit members by unit id.
+	administrativeUnitMembers map[string][]string
+	// ownedObjects are ids of objects by owner user id.
+	ownedObjects map[string][]string
+	// objects are all directory objects by id, they are used for members rendering.
+	objects map[string]map[string]any
+	// deletedGroups are still listed, but their members can't be fetched (as with eventual consistency).
+	deletedGroups StringSet
+
+	throttledRequests int
+	retryAfter        string
+	requestsCount     map[string]int
+
+	responseDelay         time.Duration
+	inFlightRequests      int
+	maxConcurrentRequests int
+}
+
+func NewAzureGraphFakeServer() *AzureGraphFakeServer {
+	s := &AzureGraphFakeServer{
+		pageSize:      defaultAzureGraphFakePageSize,
+		members:       make(map[string][]string),
+		objects:       make(map[string]map[string]any),
+		deletedGroups: NewStringSet(),
+		requestsCount: make(map[string]int),
+
+		administrativeUnitMembers: make(map[string][]string),
+		ownedObjects:              make(map[string][]string),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1.0/users", s.handleUsers)
+	mux.HandleFunc("/v1.0/users/", s.handleOwnedObjects)
+	mux.HandleFunc("/v1.0/directory/administrativeUnits/", s.handleAdministrativeUnitMembers)
+	mux.HandleFunc("/v1.0/servicePrincipals", s.handleServicePrincipals)
+	mux.HandleFunc("/v1.0/groups", s.handleGroups)
+	mux.HandleFunc("/v1.0/groups/", s.handleGroupMembers)
+	s.server = httptest.NewServer(s.withThrottling(mux))
+	return s
+}
+
+func (s *AzureGraphFakeServer) Close() {
+	s.server.Close()
+}
+
+// URL is a base url of the server, which should be set to the graph client request adapter.
+func (s *AzureGraphFakeServer) URL() string {
+	return s.server.URL + "/v1.0"
+}
+
+// newAzureReal creates AzureReal which sends unauthenticated requests to the fake server.
+func (s *AzureGraphFakeServer) newAzureReal(cfg *AzureConfig, logger appLoggerType) (*AzureReal, error) {
+	setAzureConfigDefaults(cfg)
+	graphClient, err := newGraphClientWithAuth(&abstractionsauth.AnonymousAuthenticationProvider{}, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	graphClient.GetAdapter().SetBaseUrl(s.URL())
+	return newAzureRealWithGraphClient(cfg, graphClient, nil, logger)
+}
+
+func (s *AzureGraphFakeServer) setPageSize(pageSize int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageSize = pageSize
+}
+
+// addUser adds user with raw MS Graph fields (id is required).
+func (s *AzureGraphFakeServer) addUser(fields map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := withODataType(fields, graphODataTypeUser)
+	s.users = append(s.users, user)
+	s.objects[user["id"].(string)] = user
+}
+
+// addServicePrincipal adds service principal with raw MS Graph fields (id is required).
+func (s *AzureGraphFakeServer) addServicePrincipal(fields map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	servicePrincipal := withODataType(fields, graphODataTypeServicePrincipal)
+	s.servicePrincipals = append(s.servicePrincipals, servicePrincipal)
+	s.objects[servicePrincipal["id"].(string)] = servicePrincipal
+}
+
+// addDirectoryObject adds object of any type (e.g. device), which can only be a group member.
+func (s *AzureGraphFakeServer) addDirectoryObject(fields map[string]any, odataType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object := withODataType(fields, odataType)
+	s.objects[object["id"].(string)] = object
+}
+
+// addGroup adds group with raw MS Graph fields (id is required) and ids of its members.
+func (s *AzureGraphFakeServer) addGroup(fields map[string]any, memberIDs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	group := withODataType(fields, graphODataTypeGroup)
+	s.groups = append(s.groups, group)
+	s.objects[group["id"].(string)] = group
+	s.members[group["id"].(string)] = memberIDs
+}
+
+// addAdministrativeUnit adds administrative unit with ids of its members.
+func (s *AzureGraphFakeServer) addAdministrativeUnit(unitID string, memberIDs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.administrativeUnitMembers[unitID] = memberIDs
+}
+
+// setOwnedObjects sets ids of objects owned by the user with ownerID.
+func (s *AzureGraphFakeServer) setOwnedObjects(ownerID string, objectIDs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ownedObjects[ownerID] = objectIDs
+}
+
+// markGroupDeleted makes members requests of the group fail with 404, while the group is still listed.
+func (s *AzureGraphFakeServer) markGroupDeleted(groupID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deletedGroups.Add(groupID)
+}
+
+// throttleNextRequests makes the server respond 429 to the next count requests.
+// Empty retryAfter means no Retry-After header.
+func (s *AzureGraphFakeServer) throttleNextRequests(count int, retryAfter string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttledRequests = count
+	s.retryAfter = retryAfter
+}
+
+// setResponseDelay makes the server respond slower, so concurrent requests overlap.
+func (s *AzureGraphFakeServer) setResponseDelay(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responseDelay = delay
+}
+
+// getMaxConcurrentRequests returns maximum number of requests, which were processed at the same time.
+func (s *AzureGraphFakeServer) getMaxConcurrentRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxConcurrentRequests
+}
+
+// getRequestsCount returns number of requests (including throttled ones) to the path, e.g. "/v1.0/users".
+func (s *AzureGraphFakeServer) getRequestsCount(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requestsCount[path]
+}
+
+func withODataType(fields map[string]any, odataType string) map[string]any {
+	object := map[string]any{"@odata.type": odataType}
+	for key, value := range fields {
+		object[key] = value
+	}
+	return object
+}
+
+func (s *AzureGraphFakeServer) withThrottling(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requestsCount[r.URL.Path]++
+		throttle := s.throttledRequests > 0
+		if throttle {
+			s.throttledRequests--
+		}
+		retryAfter := s.retryAfter
+		responseDelay := s.responseDelay
+		s.inFlightRequests++
+		if s.inFlightRequests > s.maxConcurrentRequests {
+			s.maxConcurrentRequests = s.inFlightRequests
+		}
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			s.inFlightRequests--
+			s.mu.Unlock()
+		}()
+		time.Sleep(responseDelay)
+
+		if throttle {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			writeGraphFakeError(w, http.StatusTooManyRequests, "TooManyRequests", "Too many requests")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *AzureGraphFakeServer) handleUsers(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	users := s.users
+	s.mu.Unlock()
+	s.writeCollection(w, r, users)
+}
+
+func (s *AzureGraphFakeServer) handleServicePrincipals(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	servicePrincipals := s.servicePrincipals
+	s.mu.Unlock()
+	s.writeCollection(w, r, servicePrincipals)
+}
+
+func (s *AzureGraphFakeServer) handleGroups(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	var groups []map[string]any
+	for _, group := range s.groups {
+		groupWithMembers := make(map[string]any)
+		for key, value := range group {
+			groupWithMembers[key] = value
+		}
+		if strings.HasPrefix(r.URL.Query().Get("$expand"), "members") {
+			// As real MS Graph, $expand returns only first msgraphExpandLimit members.
+			var members []any
+			for _, memberID := range s.members[group["id"].(string)] {
+				if len(members) == msgraphExpandLimit {
+					break
+				}
+				members = append(members, selectGraphFakeFields(s.objects[memberID], []string{"id"}))
+			}
+			groupWithMembers["members"] = members
+		}
+		groups = append(groups, groupWithMembers)
+	}
+	s.mu.Unlock()
+	s.writeCollection(w, r, groups, "members")
+}
+
+func (s *AzureGraphFakeServer) handleGroupMembers(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1.0/groups/"), "/")
+	if len(parts) != 2 || parts[1] != "members" {
+		writeGraphFakeError(w, http.StatusNotFound, "Request_ResourceNotFound", "Unknown path "+r.URL.Path)
+		return
+	}
+
+	s.mu.Lock()
+	memberIDs, ok := s.members[parts[0]]
+	if s.deletedGroups.Contains(parts[0]) {
+		ok = false
+	}
+	members := s.getObjects(memberIDs)
+	s.mu.Unlock()
+	if !ok {
+		writeGraphFakeError(w, http.StatusNotFound, "Request_ResourceNotFound", "Group "+parts[0]+" doesn't exist")
+		return
+	}
+	s.writeCollection(w, r, members)
+}
+
+func (s *AzureGraphFakeServer) handleAdministrativeUnitMembers(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1.0/directory/administrativeUnits/"), "/")
+	if len(parts) != 2 || parts[1] != "members" {
+		writeGraphFakeError(w, http.StatusNotFound, "Request_ResourceNotFound", "Unknown path "+r.URL.Path)
+		return
+	}
+
+	s.mu.Lock()
+	memberIDs, ok := s.administrativeUnitMembers[parts[0]]
+	members := s.getObjects(memberIDs)
+	s.mu.Unlock()
+	if !ok {
+		writeGraphFakeError(w, http.StatusNotFound, "Request_ResourceNotFound", "Administrative unit "+parts[0]+" doesn't exist")
+		return
+	}
+	s.writeCollection(w, r, members)
+}
+
+// handleOwnedObjects serves objects owned by user, which is referenced by id or principal name.
+func (s *AzureGraphFakeServer) handleOwnedObjects(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1.0/users/"), "/")
+	if len(parts) != 2 || parts[1] != "ownedObjects" {
+		writeGraphFakeError(w, http.StatusNotFound, "Request_ResourceNotFound", "Unknown path "+r.URL.Path)
+		return
+	}
+
+	s.mu.Lock()
+	var ownerID string
+	for _, user := range s.users {
+		if user["id"] == parts[0] || user["userPrincipalName"] == parts[0] {
+			ownerID = user["id"].(string)
+		}
+	}
+	owned := s.getObjects(s.ownedObjects[ownerID])
+	s.mu.Unlock()
+	if ownerID == "" {
+		writeGraphFakeError(w, http.StatusNotFound, "Request_ResourceNotFound", "User "+parts[0]+" doesn't exist")
+		return
+	}
+	s.writeCollection(w, r, owned)
+}
+
+// getObjects should be called under the lock.
+func (s *AzureGraphFakeServer) getObjects(ids []string) []map[string]any {
+	var objects []map[string]any
+	for _, id := range ids {
+		objects = append(objects, s.objects[id])
+	}
+	return objects
+}
+
+// writeCollection applies $filter, $select and paging ($skiptoken is an offset in the fake) to the objects.
+// extraFields are kept regardless of $select (e.g. expanded members).
+func (s *AzureGraphFakeServer) writeCollection(w http.ResponseWriter, r *http.Request, objects []map[string]any, extraFields ...string) {
+	query := r.URL.Query()
+
+	filtered, err := filterGraphFakeObjects(objects, query.Get("$filter"))
+	if err != nil {
+		writeGraphFakeError(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+
+	offset := 0
+	if skipToken := query.Get("$skiptoken"); skipToken != "" {
+		offset, err = strconv.Atoi(skipToken)
+		if err != nil || offset < 0 || offset > len(filtered) {
+			writeGraphFakeError(w, http.StatusBadRequest, "BadRequest", "Invalid $skiptoken "+skipToken)
+			return
+		}
+	}
+	s.mu.Lock()
+	pageSize := s.pageSize
+	s.mu.Unlock()
+	end := offset + pageSize
+	if end > len(filtered) {
+		end = len(filtered)
+	}
+
+	var fieldsToSelect []string
+	if selectParam := query.Get("$select"); selectParam != "" {
+		fieldsToSelect = append(strings.Split(selectParam, ","), extraFields...)
+	}
+	page := make([]map[string]any, 0, end-offset)
+	for _, object := range filtered[offset:end] {
+		page = append(page, selectGraphFakeFields(object, fieldsToSelect))
+	}
+
+	response := map[string]any{"value": page}
+	if end < len(filtered) {
+		nextQuery := url.Values{}
+		for key, values := range query {
+			nextQuery[key] = values
+		}
+		nextQuery.Set("$skiptoken", strconv.Itoa(end))
+		response["@odata.nextLink"] = s.server.URL + r.URL.Path + "?" + nextQuery.Encode()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// selectGraphFakeFields returns only selected fields, @odata.type is always returned as in real MS Graph.
+func selectGraphFakeFields(object map[string]any, fieldsToSelect []string) map[string]any {
+	if len(fieldsToSelect) == 0 {
+		return object
+	}
+	selected := map[string]any{"@odata.type": object["@odata.type"]}
+	for _, field := range fieldsToSelect {
+		if value, ok := object[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected
+}
+
+func filterGraphFakeObjects(objects []map[string]any, filter string) ([]map[string]any, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return objects, nil
+	}
+	var predicates []func(map[string]any) bool
+	for _, clause := range strings.Split(filter, " and ") {
+		predicate, err := parseGraphFakeFilterClause(clause)
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, predicate)
+	}
+
+	var filtered []map[string]any
+	for _, object := range objects {
+		matched := true
+		for _, predicate := range predicates {
+			if !predicate(object) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			filtered = append(filtered, object)
+		}
+	}
+	return filtered, nil
+}
+
+func parseGraphFakeFilterClause(clause string) (func(map[string]any) bool, error) {
+	clause = strings.TrimSpace(clause)
+	for strings.HasPrefix(clause, "(") && strings.HasSuffix(clause, ")") {
+		clause = strings.TrimSpace(clause[1 : len(clause)-1])
+	}
+
+	if match := graphFakeStartsWithRegexp.FindStringSubmatch(clause); match != nil {
+		field, prefix := match[1], match[2]
+		return func(object map[string]any) bool {
+			value, ok := object[field].(string)
+			return ok && strings.HasPrefix(value, prefix)
+		}, nil
+	}
+	if match := graphFakeEqRegexp.FindStringSubmatch(clause); match != nil {
+		field, operator := match[1], match[2]
+		var expected any = match[3]
+		if match[4] != "" {
+			expected = match[4] == "true"
+		}
+		return func(object map[string]any) bool {
+			equal := object[field] == expected
+			if operator == "ne" {
+				return !equal
+			}
+			return equal
+		}, nil
+	}
+	return nil, errors.Errorf("unsupported filter clause: %s", clause)
+}
+
+func writeGraphFakeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"code": code, "message": message},
+	})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	khttp "github.com/microsoft/kiota-http-go"
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	msgraphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAzureMaxRetries     = 5
+	defaultAzureInitialBackoff = 1 * time.Second
+	defaultAzureMaxBackoff     = 1 * time.Minute
+)
+
+// graphRetryHandler is a kiota middleware which replaces the default kiota retry handler.
+// Every attempt has its own timeout, throttled (429) and temporarily failed requests are retried
+// with respect to Retry-After header or with exponential backoff otherwise.
+// Retries are stopped when the request context (overall fetch deadline) is done.
+type graphRetryHandler struct {
+	requestTimeout time.Duration
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+
+	logger appLoggerType
+}
+
+func newGraphRetryHandler(cfg *AzureConfig, logger appLoggerType) *graphRetryHandler {
+	retry := cfg.Retry
+	maxRetries := defaultAzureMaxRetries
+	if retry.MaxRetries != nil {
+		maxRetries = *retry.MaxRetries
+	}
+	if retry.InitialBackoff == 0 {
+		retry.InitialBackoff = defaultAzureInitialBackoff
+	}
+	if retry.MaxBackoff == 0 {
+		retry.MaxBackoff = defaultAzureMaxBackoff
+	}
+	return &graphRetryHandler{
+		requestTimeout: cfg.Timeout,
+		maxRetries:     maxRetries,
+		initialBackoff: retry.InitialBackoff,
+		maxBackoff:     retry.MaxBackoff,
+		logger:         logger,
+	}
+}
+
+// newGraphHTTPClient creates http client with the default MS Graph middlewares, except the retry one.
+func newGraphHTTPClient(cfg *AzureConfig, logger appLoggerType) *http.Client {
+	clientOptions := msgraphsdk.GetDefaultClientOptions()
+	var middlewares []khttp.Middleware
+	for _, middleware := range msgraphcore.GetDefaultMiddlewaresWithOptions(&clientOptions) {
+		if _, isRetry := middleware.(*khttp.RetryHandler); isRetry {
+			middleware = newGraphRetryHandler(cfg, logger)
+		}
+		middlewares = append(middlewares, middleware)
+	}
+	client := msgraphcore.GetDefaultClient(&clientOptions, middlewares...)
+	// Deadlines are controlled by the per-attempt timeouts and the request context.
+	client.Timeout = 0
+	return client
+}
+
+func (h *graphRetryHandler) Intercept(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		response, err := h.doAttempt(pipeline, middlewareIndex, req)
+		if attempt >= h.maxRetries || !isRetriableGraphRequest(req) || !isRetriableGraphResponse(ctx, response, err) {
+			return response, err
+		}
+
+		delay := h.retryDelay(response, attempt)
+		logger := h.logger.With("path", req.URL.Path, "attempt", attempt+1, "delay", delay)
+		if err != nil {
+			logger.Warnw("MS Graph request failed, going to retry", zap.Error(err))
+		} else {
+			logger.Warnw("MS Graph request is throttled or temporarily failed, going to retry", "status", response.StatusCode)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, errors.Wrap(ctx.Err(), "MS Graph fetch deadline exceeded while waiting for retry")
+		case <-timer.C:
+		}
+	}
+}
+
+// doAttempt sends the request with the per-attempt timeout.
+// The response body is read inside the attempt, since the attempt context is cancelled on return.
+func (h *graphRetryHandler) doAttempt(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	response, err := pipeline.Next(req.WithContext(ctx), middlewareIndex)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read MS Graph response")
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	return response, nil
+}
+
+// retryDelay returns Retry-After value if it is present or exponential backoff otherwise.
+func (h *graphRetryHandler) retryDelay(response *http.Response, attempt int) time.Duration {
+	if response != nil {
+		if delay, ok := parseRetryAfter(response.Header.Get("Retry-After")); ok {
+			return delay
+		}
+	}
+	delay := h.initialBackoff
+	for i := 0; i < attempt && delay < h.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > h.maxBackoff {
+		delay = h.maxBackoff
+	}
+	return delay
+}
+
+// parseRetryAfter supports both forms of the header: delay in seconds and HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+func isRetriableGraphRequest(req *http.Request) bool {
+	// Only requests without body can be safely resent, sync only does GET requests anyway.
+	return req.Body == nil || req.Body == http.NoBody
+}
+
+func isRetriableGraphResponse(ctx context.Context, response *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		// Overall deadline is exceeded, no sense to retry.
+		return false
+	}
+	if err != nil {
+		// Network errors and attempt timeouts.
+		return true
+	}
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusBadGateway:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	abstractionsauth "github.com/microsoft/kiota-abstractions-go/authentication"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAzureReal(t *testing.T, serverURL string, cfg *AzureConfig) *AzureReal {
+	logger := getDevelopmentLogger()
+	setAzureConfigDefaults(cfg)
+	graphClient, err := newGraphClientWithAuth(&abstractionsauth.AnonymousAuthenticationProvider{}, cfg, logger)
+	require.NoError(t, err)
+	graphClient.GetAdapter().SetBaseUrl(serverURL + "/v1.0")
+	azure, err := newAzureRealWithGraphClient(cfg, graphClient, nil, logger)
+	require.NoError(t, err)
+	return azure
+}
+
+func writeGraphUsersPage(w http.ResponseWriter, nextLink string, ids ...string) {
+	w.Header().Set("Content-Type", "application/json")
+	var values string
+	for i, id := range ids {
+		if i > 0 {
+			values += ","
+		}
+		values += fmt.Sprintf(`{"id":%q,"userPrincipalName":"%s@acme.com"}`, id, id)
+	}
+	if nextLink != "" {
+		_, _ = fmt.Fprintf(w, `{"value":[%s],"@odata.nextLink":%q}`, values, nextLink)
+		return
+	}
+	_, _ = fmt.Fprintf(w, `{"value":[%s]}`, values)
+}
+
+func TestGraphRetryThrottledPages(t *testing.T) {
+	var firstPageRequests, secondPageRequests atomic.Int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			if secondPageRequests.Add(1) == 1 {
+				// No Retry-After: exponential backoff is used.
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			writeGraphUsersPage(w, "", "bob")
+			return
+		}
+		if firstPageRequests.Add(1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		writeGraphUsersPage(w, server.URL+"/v1.0/users?page=2", "alice")
+	}))
+	defer server.Close()
+
+	azure := newTestAzureReal(t, server.URL, &AzureConfig{
+		Timeout:      time.Second,
+		FetchTimeout: 10 * time.Second,
+		Retry:        AzureRetryConfig{InitialBackoff: 10 * time.Millisecond},
+	})
+	users, err := azure.GetUsers()
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	require.Equal(t, int32(3), firstPageRequests.Load())
+	require.Equal(t, int32(2), secondPageRequests.Load())
+}
+
+func TestGraphRetryRequestTimeout(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		writeGraphUsersPage(w, "", "alice")
+	}))
+	defer server.Close()
+
+	azure := newTestAzureReal(t, server.URL, &AzureConfig{
+		Timeout:      100 * time.Millisecond,
+		FetchTimeout: 10 * time.Second,
+		Retry:        AzureRetryConfig{InitialBackoff: 10 * time.Millisecond},
+	})
+	users, err := azure.GetUsers()
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	require.Equal(t, int32(2), requests.Load())
+}
+
+func TestGraphRetryFetchDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	azure := newTestAzureReal(t, server.URL, &AzureConfig{
+		Timeout:      time.Second,
+		FetchTimeout: 300 * time.Millisecond,
+		Retry:        AzureRetryConfig{MaxRetries: ptr(10)},
+	})
+	start := time.Now()
+	_, err := azure.GetUsers()
+	require.Error(t, err)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestGraphRetryDelay(t *testing.T) {
+	handler := newGraphRetryHandler(&AzureConfig{
+		Retry: AzureRetryConfig{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second},
+	}, getDevelopmentLogger())
+
+	require.Equal(t, time.Second, handler.retryDelay(nil, 0))
+	require.Equal(t, 2*time.Second, handler.retryDelay(nil, 1))
+	require.Equal(t, 4*time.Second, handler.retryDelay(nil, 2))
+	require.Equal(t, 5*time.Second, handler.retryDelay(nil, 3))
+	require.Equal(t, 5*time.Second, handler.retryDelay(nil, 30))
+
+	response := &http.Response{Header: http.Header{}}
+	response.Header.Set("Retry-After", "7")
+	require.Equal(t, 7*time.Second, handler.retryDelay(response, 0))
+
+	response.Header.Set("Retry-After", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	require.Equal(t, time.Duration(0), handler.retryDelay(response, 0))
+
+	response.Header.Set("Retry-After", "garbage")
+	require.Equal(t, 2*time.Second, handler.retryDelay(response, 1))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	msgraphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
+	msgraphdirectory "github.com/microsoftgraph/msgraph-sdk-go/directory"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	msgraphusers "github.com/microsoftgraph/msgraph-sdk-go/users"
+	"github.com/pkg/errors"
+)
+
+// azureGroupSelector decides which of the fetched groups are synced, see AzureGroupSelectionConfig.
+type azureGroupSelector struct {
+	*groupSelector
+
+	displayNameSuffix     string
+	administrativeUnitIDs []string
+	owners                []string
+}
+
+func newAzureGroupSelector(cfg *AzureConfig) (*azureGroupSelector, error) {
+	selection := cfg.GroupSelection
+	selector, err := newGroupSelector(
+		selection.IDs,
+		selection.DisplayNamePrefixes,
+		selection.IncludeDisplayNameRegexes,
+		selection.ExcludeDisplayNameRegexes,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &azureGroupSelector{
+		groupSelector:         selector,
+		displayNameSuffix:     cfg.GroupsDisplayNameSuffixPostFilter,
+		administrativeUnitIDs: selection.AdministrativeUnitIDs,
+		owners:                selection.Owners,
+	}, nil
+}
+
+// isSelected checks the group against the rules, directoryGroupIDs are ids of groups
+// from administrative units and owned by the configured owners.
+func (s *azureGroupSelector) isSelected(id, displayName string, directoryGroupIDs StringSet) bool {
+	if s.displayNameSuffix != "" && !strings.HasSuffix(displayName, s.displayNameSuffix) {
+		return false
+	}
+	if len(s.administrativeUnitIDs) == 0 && len(s.owners) == 0 {
+		directoryGroupIDs = nil
+	}
+	return s.groupSelector.isSelected(id, displayName, directoryGroupIDs)
+}
+
+// getDirectoryGroupIDs returns ids of groups, which are members of the configured administrative units
+// or owned by the configured owners.
+func (a *AzureReal) getDirectoryGroupIDs(ctx context.Context) (StringSet, error) {
+	groupIDs := NewStringSet()
+	addGroups := func(objects []models.DirectoryObjectable) {
+		for _, object := range objects {
+			if handleNil(object.GetOdataType()) == graphODataTypeGroup && object.GetId() != nil {
+				groupIDs.Add(*object.GetId())
+			}
+		}
+	}
+
+	for _, unitID := range a.groupSelector.administrativeUnitIDs {
+		objects, err := a.getAdministrativeUnitMembers(ctx, unitID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get members of administrative unit %s", unitID)
+		}
+		addGroups(objects)
+	}
+	for _, owner := range a.groupSelector.owners {
+		objects, err := a.getOwnedObjects(ctx, owner)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get objects owned by %s", owner)
+		}
+		addGroups(objects)
+	}
+	return groupIDs, nil
+}
+
+func (a *AzureReal) getAdministrativeUnitMembers(ctx context.Context, unitID string) ([]models.DirectoryObjectable, error) {
+	// https://learn.microsoft.com/en-us/graph/api/administrativeunit-list-members
+	configuration := &msgraphdirectory.AdministrativeUnitsItemMembersRequestBuilderGetRequestConfiguration{
+		QueryParameters: &msgraphdirectory.AdministrativeUnitsItemMembersRequestBuilderGetQueryParameters{
+			Select: []string{"id"},
+		},
+	}
+	result, err := a.graphClient.Directory().AdministrativeUnits().ByAdministrativeUnitId(unitID).Members().Get(ctx, configuration)
+	if err != nil {
+		return nil, err
+	}
+	return a.collectDirectoryObjects(ctx, result)
+}
+
+func (a *AzureReal) getOwnedObjects(ctx context.Context, owner string) ([]models.DirectoryObjectable, error) {
+	// https://learn.microsoft.com/en-us/graph/api/user-list-ownedobjects
+	headers := abstractions.NewRequestHeaders()
+	headers.Add("ConsistencyLevel", "eventual")
+	configuration := &msgraphusers.ItemOwnedObjectsRequestBuilderGetRequestConfiguration{
+		Headers: headers,
+		QueryParameters: &msgraphusers.ItemOwnedObjectsRequestBuilderGetQueryParameters{
+			Select: []string{"id"},
+		},
+	}
+	result, err := a.graphClient.Users().ByUserId(owner).OwnedObjects().Get(ctx, configuration)
+	if err != nil {
+		return nil, err
+	}
+	return a.collectDirectoryObjects(ctx, result)
+}
+
+func (a *AzureReal) collectDirectoryObjects(
+	ctx context.Context,
+	result models.DirectoryObjectCollectionResponseable,
+) ([]models.DirectoryObjectable, error) {
+	pageIterator, err := msgraphcore.NewPageIterator[models.DirectoryObjectable](
+		result,
+		a.graphClient.GetAdapter(),
+		models.CreateDirectoryObjectCollectionResponseFromDiscriminatorValue,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create directory objects page iterator")
+	}
+
+	var objects []models.DirectoryObjectable
+	err = pageIterator.Iterate(ctx, func(object models.DirectoryObjectable) bool {
+		objects = append(objects, object)
+		// Return true to continue the iteration.
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to iterate over Azure directory objects")
+	}
+	return objects, nil
+}
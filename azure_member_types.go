@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	msgraphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	msgraphserviceprincipals "github.com/microsoftgraph/msgraph-sdk-go/serviceprincipals"
+	"github.com/pkg/errors"
+)
+
+const (
+	graphODataTypePrefix           = "#microsoft.graph."
+	graphODataTypeUser             = graphODataTypePrefix + "user"
+	graphODataTypeGroup            = graphODataTypePrefix + "group"
+	graphODataTypeServicePrincipal = graphODataTypePrefix + "servicePrincipal"
+	graphODataTypeDevice           = graphODataTypePrefix + "device"
+
+	azureUserTypeGuestValue = "Guest"
+
+	azureNameFieldPrincipalName = "principal_name"
+	azureNameFieldEmail         = "email"
+	azureNameFieldDisplayName   = "display_name"
+	azureNameFieldAppID         = "app_id"
+
+	defaultAzureServicePrincipalsNameField  = azureNameFieldDisplayName
+	defaultAzureServicePrincipalsNamePrefix = "robot-"
+	defaultAzureGuestsNameField             = azureNameFieldEmail
+	defaultAzureGuestsNamePrefix            = "guest-"
+)
+
+var defaultServicePrincipalFieldsToSelect = []string{
+	"id",
+	"appId",
+	"displayName",
+	"accountEnabled",
+}
+
+// azureMemberNaming builds YTsaurus username base for non-regular users, username replacements are applied later.
+type azureMemberNaming struct {
+	nameField  string
+	namePrefix string
+}
+
+func newAzureMemberNaming(nameField, namePrefix string, allowedFields ...string) (azureMemberNaming, error) {
+	for _, field := range allowedFields {
+		if field == nameField {
+			return azureMemberNaming{nameField: nameField, namePrefix: namePrefix}, nil
+		}
+	}
+	return azureMemberNaming{}, errors.Errorf("unsupported name field %q, expected one of: %s", nameField, strings.Join(allowedFields, ", "))
+}
+
+// buildName returns empty string if the name field is empty.
+func (n azureMemberNaming) buildName(user AzureUser) string {
+	var name string
+	switch n.nameField {
+	case azureNameFieldPrincipalName:
+		name = user.PrincipalName
+	case azureNameFieldEmail:
+		name = user.Email
+	case azureNameFieldDisplayName:
+		name = user.DisplayName
+	case azureNameFieldAppID:
+		name = user.AppID
+	}
+	if name == "" {
+		return ""
+	}
+	return n.namePrefix + name
+}
+
+// ignoredMembersCounter counts group members, which are not synced, by their type.
+// It is shared between members fetching workers.
+type ignoredMembersCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newIgnoredMembersCounter() *ignoredMembersCounter {
+	return &ignoredMembersCounter{counts: make(map[string]int)}
+}
+
+func (c *ignoredMembersCounter) add(odataType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[strings.TrimPrefix(odataType, graphODataTypePrefix)]++
+}
+
+func (c *ignoredMembersCounter) get() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int, len(c.counts))
+	for odataType, count := range c.counts {
+		counts[odataType] = count
+	}
+	return counts
+}
+
+// isSyncedMemberType returns true for members, which may be synced as YTsaurus users.
+// Members without @odata.type are considered users.
+func (a *AzureReal) isSyncedMemberType(odataType string) bool {
+	switch odataType {
+	case "", graphODataTypeUser:
+		return true
+	case graphODataTypeServicePrincipal:
+		return a.servicePrincipalsEnabled
+	}
+	return false
+}
+
+func (a *AzureReal) getServicePrincipals(ctx context.Context) ([]SourceUser, error) {
+	servicePrincipalsRaw, err := a.getServicePrincipalsRaw(ctx, defaultServicePrincipalFieldsToSelect, a.servicePrincipalsFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	skipped := 0
+	var users []SourceUser
+	for _, servicePrincipal := range servicePrincipalsRaw {
+		user := AzureUser{
+			Type:        azureUserTypeServicePrincipal,
+			AzureID:     handleNil(servicePrincipal.GetId()),
+			AppID:       handleNil(servicePrincipal.GetAppId()),
+			DisplayName: handleNil(servicePrincipal.GetDisplayName()),
+
+			AccountDisabled: servicePrincipal.GetAccountEnabled() != nil && !*servicePrincipal.GetAccountEnabled(),
+		}
+		user.Name = a.servicePrincipalsNaming.buildName(user)
+
+		a.maybePrintDebugLogs(
+			user.AzureID,
+			"appId", user.AppID,
+			"displayName", user.DisplayName,
+			"accountDisabled", user.AccountDisabled,
+		)
+
+		if user.Name == "" {
+			a.logger.Debugw("Skipping service principal with empty name field", "id", user.AzureID)
+			skipped++
+			continue
+		}
+		users = append(users, user)
+	}
+
+	a.logger.Infow("Fetched service principals from Azure AD", "got", len(servicePrincipalsRaw), "skipped", skipped)
+	return users, nil
+}
+
+func (a *AzureReal) getServicePrincipalsRaw(ctx context.Context, fieldsToSelect []string, filter string) ([]models.ServicePrincipalable, error) {
+	// https://learn.microsoft.com/en-us/graph/api/serviceprincipal-list
+	headers := abstractions.NewRequestHeaders()
+	headers.Add("ConsistencyLevel", "eventual")
+	count := true
+	requestConfig := &msgraphserviceprincipals.ServicePrincipalsRequestBuilderGetRequestConfiguration{
+		Headers: headers,
+		QueryParameters: &msgraphserviceprincipals.ServicePrincipalsRequestBuilderGetQueryParameters{
+			Count:  &count,
+			Filter: &filter,
+			Select: fieldsToSelect,
+		},
+	}
+	result, err := a.graphClient.ServicePrincipals().Get(ctx, requestConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get service principals")
+	}
+
+	pageIterator, err := msgraphcore.NewPageIterator[models.ServicePrincipalable](
+		result,
+		a.graphClient.GetAdapter(),
+		models.CreateServicePrincipalCollectionResponseFromDiscriminatorValue,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create service principals page iterator")
+	}
+
+	var rawServicePrincipals []models.ServicePrincipalable
+	err = pageIterator.Iterate(ctx, func(servicePrincipal models.ServicePrincipalable) bool {
+		rawServicePrincipals = append(rawServicePrincipals, servicePrincipal)
+		// Return true to continue the iteration.
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to iterate over Azure service principals")
+	}
+	return rawServicePrincipals, nil
+}
@@ -1,19 +1,38 @@
 package main
 
 import (
+	"strings"
+
 	"go.ytsaurus.tech/yt/go/yson"
 )
 
+const (
+	// azureUserTypeGuest is a type of guest users (userType Guest in Azure).
+	azureUserTypeGuest = "guest"
+	// azureUserTypeServicePrincipal is a type of service principals, which are synced as YTsaurus users.
+	azureUserTypeServicePrincipal = "service_principal"
+)
+
 type AzureUser struct {
 	// PrincipalName is unique human-readable Azure user field, used (possibly with changes)
 	// for the corresponding YTsaurus user's `name` attribute.
 	PrincipalName string `yson:"principal_name"`
+	// Type is empty for regular (member) users, see azureUserType* constants for others.
+	Type string `yson:"type,omitempty"`
+	// Name overrides PrincipalName for YTsaurus username, it is built by the per-type naming scheme.
+	Name string `yson:"name,omitempty"`
+	// AppID is an application id of the service principal.
+	AppID string `yson:"app_id,omitempty"`
 
 	AzureID     ObjectID `yson:"id"`
 	Email       string   `yson:"email"`
 	FirstName   string   `yson:"first_name"`
 	LastName    string   `yson:"last_name"`
 	DisplayName string   `yson:"display_name"`
+
+	// AccountDisabled reflects Azure accountEnabled=false. It is not stored in YTsaurus source attribute,
+	// since disabled accounts are banned in YTsaurus with the corresponding reason.
+	AccountDisabled bool `yson:"-"`
 }
 
 func NewAzureUser(attributes map[string]any) (*AzureUser, error) {
@@ -35,9 +54,28 @@ func (au AzureUser) GetID() ObjectID {
 }
 
 func (au AzureUser) GetName() string {
+	if au.Name != "" {
+		return au.Name
+	}
 	return au.PrincipalName
 }
 
+func (au AzureUser) GetEmail() string {
+	return au.Email
+}
+
+// GetFullName returns display name, or first and last names if it is empty.
+func (au AzureUser) GetFullName() string {
+	if au.DisplayName != "" {
+		return au.DisplayName
+	}
+	return strings.TrimSpace(au.FirstName + " " + au.LastName)
+}
+
+func (au AzureUser) IsEnabled() bool {
+	return !au.AccountDisabled
+}
+
 func (au AzureUser) GetRaw() (map[string]any, error) {
 	bytes, err := yson.Marshal(au)
 	if err != nil {
@@ -2,12 +2,12 @@ package main
 
 import (
 	"context"
-	"os"
-	"strings"
+	"sync"
 	"time"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	abstractions "github.com/microsoft/kiota-abstractions-go"
+	abstractionsauth "github.com/microsoft/kiota-abstractions-go/authentication"
+	kiotaauth "github.com/microsoft/kiota-authentication-azure-go"
 	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
 	msgraphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
 	msgraphgroups "github.com/microsoftgraph/msgraph-sdk-go/groups"
@@ -19,8 +19,10 @@ import (
 const (
 	scope                    = "https://graph.microsoft.com/.default"
 	msgraphExpandLimit       = 20
-	defaultAzureTimeout      = 3 * time.Second
-	defaultAzureSecretEnvVar = "AZURE_CLIENT_SECRET"
+	defaultAzureTimeout      = 30 * time.Second
+	defaultAzureFetchTimeout = 10 * time.Minute
+
+	defaultAzureMembersFetchConcurrency = 8
 )
 
 var (
@@ -32,6 +34,7 @@ var (
 		"surname",
 		"displayName",
 		"accountEnabled",
+		"userType",
 	}
 	defaultGroupFieldsToSelect = []string{
 		"id",
@@ -41,13 +44,24 @@ var (
 
 type AzureReal struct {
 	graphClient *msgraphsdk.GraphServiceClient
+	// cfg and clientSecret are used for graph client rebuild on the secret rotation.
+	cfg          *AzureConfig
+	clientSecret *secretReader
+
+	usersFilter   string
+	groupsFilter  string
+	groupSelector *azureGroupSelector
 
-	usersFilter                       string
-	groupsFilter                      string
-	groupsDisplayNameSuffixPostFilter string
+	logger appLoggerType
+	// fetchTimeout is an overall deadline of users or groups fetching, requests have their own timeouts.
+	fetchTimeout            time.Duration
+	membersFetchConcurrency int
 
-	logger  appLoggerType
-	timeout time.Duration
+	servicePrincipalsEnabled bool
+	servicePrincipalsFilter  string
+	servicePrincipalsNaming  azureMemberNaming
+	guestsEnabled            bool
+	guestsNaming             azureMemberNaming
 
 	debugAzureIDs []string
 }
@@ -58,40 +72,145 @@ func NewAzureReal(cfg *AzureConfig, logger appLoggerType) (*AzureReal, error) {
 	if cfg.ClientSecretEnvVar == "" {
 		cfg.ClientSecretEnvVar = defaultAzureSecretEnvVar
 	}
-	secret := os.Getenv(cfg.ClientSecretEnvVar)
-	if secret == "" {
-		return nil, errors.Errorf("Azure secret in %s env var shouldn't be empty", cfg.ClientSecretEnvVar)
+	setAzureConfigDefaults(cfg)
+	clientSecret := newSecretReader(cfg.ClientSecretEnvVar, cfg.ClientSecretFile)
+	graphClient, err := newGraphClient(cfg, clientSecret, logger)
+	if err != nil {
+		return nil, err
 	}
-	cred, err := azidentity.NewClientSecretCredential(
-		cfg.Tenant,
-		cfg.ClientID,
-		secret,
-		nil,
+	return newAzureRealWithGraphClient(cfg, graphClient, clientSecret, logger)
+}
+
+func setAzureConfigDefaults(cfg *AzureConfig) {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultAzureTimeout
+	}
+	if cfg.FetchTimeout == 0 {
+		cfg.FetchTimeout = defaultAzureFetchTimeout
+	}
+	if cfg.MembersFetchConcurrency == 0 {
+		cfg.MembersFetchConcurrency = defaultAzureMembersFetchConcurrency
+	}
+	if cfg.ServicePrincipals.NameField == "" {
+		cfg.ServicePrincipals.NameField = defaultAzureServicePrincipalsNameField
+	}
+	if cfg.ServicePrincipals.NamePrefix == nil {
+		namePrefix := defaultAzureServicePrincipalsNamePrefix
+		cfg.ServicePrincipals.NamePrefix = &namePrefix
+	}
+	if cfg.Guests.NameField == "" {
+		cfg.Guests.NameField = defaultAzureGuestsNameField
+	}
+	if cfg.Guests.NamePrefix == nil {
+		namePrefix := defaultAzureGuestsNamePrefix
+		cfg.Guests.NamePrefix = &namePrefix
+	}
+}
+
+func newAzureRealWithGraphClient(
+	cfg *AzureConfig,
+	graphClient *msgraphsdk.GraphServiceClient,
+	clientSecret *secretReader,
+	logger appLoggerType,
+) (*AzureReal, error) {
+	// With no workers members fetching would block until the fetch timeout.
+	if cfg.MembersFetchConcurrency < 0 {
+		return nil, errors.Errorf("members_fetch_concurrency should be positive, got %d", cfg.MembersFetchConcurrency)
+	}
+	servicePrincipalsNaming, err := newAzureMemberNaming(
+		cfg.ServicePrincipals.NameField,
+		*cfg.ServicePrincipals.NamePrefix,
+		azureNameFieldDisplayName, azureNameFieldAppID,
 	)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create Azure secret credentials")
+		return nil, errors.Wrap(err, "invalid service principals naming")
 	}
-
-	graphClient, err := msgraphsdk.NewGraphServiceClientWithCredentials(cred, []string{scope})
+	guestsNaming, err := newAzureMemberNaming(
+		cfg.Guests.NameField,
+		*cfg.Guests.NamePrefix,
+		azureNameFieldEmail, azureNameFieldPrincipalName, azureNameFieldDisplayName,
+	)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create ms graph client form secret credentials")
+		return nil, errors.Wrap(err, "invalid guests naming")
 	}
-
-	if cfg.Timeout == 0 {
-		cfg.Timeout = defaultAzureTimeout
+	groupSelector, err := newAzureGroupSelector(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid group selection")
 	}
 	return &AzureReal{
-		usersFilter:                       cfg.UsersFilter,
-		groupsFilter:                      cfg.GroupsFilter,
-		groupsDisplayNameSuffixPostFilter: cfg.GroupsDisplayNameSuffixPostFilter,
+		usersFilter:   cfg.UsersFilter,
+		groupsFilter:  cfg.GroupsFilter,
+		groupSelector: groupSelector,
+
+		graphClient:  graphClient,
+		cfg:          cfg,
+		clientSecret: clientSecret,
+		logger:       logger,
+		fetchTimeout: cfg.FetchTimeout,
+
+		membersFetchConcurrency: cfg.MembersFetchConcurrency,
+
+		servicePrincipalsEnabled: cfg.ServicePrincipals.Enabled,
+		servicePrincipalsFilter:  cfg.ServicePrincipals.Filter,
+		servicePrincipalsNaming:  servicePrincipalsNaming,
+		guestsEnabled:            cfg.Guests.Enabled,
+		guestsNaming:             guestsNaming,
 
-		graphClient:   graphClient,
-		logger:        logger,
-		timeout:       cfg.Timeout,
 		debugAzureIDs: cfg.DebugAzureIDs,
 	}, nil
 }
 
+func newGraphClient(cfg *AzureConfig, clientSecret *secretReader, logger appLoggerType) (*msgraphsdk.GraphServiceClient, error) {
+	cred, err := newAzureCredential(cfg, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := kiotaauth.NewAzureIdentityAuthenticationProviderWithScopes(cred, []string{scope})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create ms graph auth provider from %s credentials", cfg.AuthMethod)
+	}
+	return newGraphClientWithAuth(auth, cfg, logger)
+}
+
+// newGraphClientWithAuth creates ms graph client, which retries throttled requests.
+func newGraphClientWithAuth(
+	auth abstractionsauth.AuthenticationProvider,
+	cfg *AzureConfig,
+	logger appLoggerType,
+) (*msgraphsdk.GraphServiceClient, error) {
+	adapter, err := msgraphsdk.NewGraphRequestAdapterWithParseNodeFactoryAndSerializationWriterFactoryAndHttpClient(
+		auth,
+		nil,
+		nil,
+		newGraphHTTPClient(cfg, logger),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create ms graph request adapter")
+	}
+	return msgraphsdk.NewGraphServiceClient(adapter), nil
+}
+
+// ReloadSecretIfChanged rebuilds graph client if client secret file has changed.
+func (a *AzureReal) ReloadSecretIfChanged() error {
+	if a.cfg.AuthMethod != azureAuthMethodClientSecret {
+		return nil
+	}
+	_, changed, err := a.clientSecret.readIfChanged()
+	if err != nil {
+		return errors.Wrap(err, "failed to reload Azure client secret")
+	}
+	if !changed {
+		return nil
+	}
+	graphClient, err := newGraphClient(a.cfg, a.clientSecret, a.logger)
+	if err != nil {
+		return err
+	}
+	a.graphClient = graphClient
+	a.logger.Info("Azure client secret has changed, graph client is rebuilt")
+	return nil
+}
+
 func handleNil[T any](s *T) T {
 	if s != nil {
 		return *s
@@ -109,7 +228,7 @@ func (a *AzureReal) CreateGroupFromRaw(raw map[string]any) (SourceGroup, error)
 }
 
 func (a *AzureReal) GetUsers() ([]SourceUser, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), a.fetchTimeout)
 	defer cancel()
 
 	usersRaw, err := a.getUsersRaw(ctx, defaultUserFieldsToSelect, a.usersFilter)
@@ -118,6 +237,7 @@ func (a *AzureReal) GetUsers() ([]SourceUser, error) {
 	}
 
 	usersSkipped := 0
+	guestsSkipped := 0
 	var users []SourceUser
 	for _, user := range usersRaw {
 		principalName := handleNil(user.GetUserPrincipalName())
@@ -126,6 +246,9 @@ func (a *AzureReal) GetUsers() ([]SourceUser, error) {
 		firstName := handleNil(user.GetGivenName())
 		lastName := handleNil(user.GetSurname())
 		displayName := handleNil(user.GetDisplayName())
+		// accountEnabled may be missing in the response, such users are considered enabled.
+		accountDisabled := user.GetAccountEnabled() != nil && !*user.GetAccountEnabled()
+		isGuest := handleNil(user.GetUserType()) == azureUserTypeGuestValue
 
 		a.maybePrintDebugLogs(
 			id,
@@ -134,39 +257,77 @@ func (a *AzureReal) GetUsers() ([]SourceUser, error) {
 			"firstName", firstName,
 			"lastName", lastName,
 			"displayName", displayName,
+			"accountDisabled", accountDisabled,
+			"isGuest", isGuest,
 		)
 
 		if principalName == "" {
 			a.logger.Debugw("Skipping user with empty principal name", "user", user)
 			usersSkipped++
-		} else {
-			users = append(users,
-				AzureUser{
-					PrincipalName: principalName,
-					AzureID:       id,
-					Email:         mail,
-					FirstName:     firstName,
-					LastName:      lastName,
-					DisplayName:   displayName,
-				})
+			continue
+		}
+		if isGuest && !a.guestsEnabled {
+			guestsSkipped++
+			continue
 		}
+
+		azureUser := AzureUser{
+			PrincipalName: principalName,
+			AzureID:       id,
+			Email:         mail,
+			FirstName:     firstName,
+			LastName:      lastName,
+			DisplayName:   displayName,
+
+			AccountDisabled: accountDisabled,
+		}
+		if isGuest {
+			azureUser.Type = azureUserTypeGuest
+			azureUser.Name = a.guestsNaming.buildName(azureUser)
+			if azureUser.Name == "" {
+				a.logger.Debugw("Skipping guest user with empty name field", "id", id)
+				usersSkipped++
+				continue
+			}
+		}
+		users = append(users, azureUser)
 	}
 
-	a.logger.Infow("Fetched users from Azure AD", "got", len(usersRaw), "skipped", usersSkipped)
+	a.logger.Infow("Fetched users from Azure AD",
+		"got", len(usersRaw),
+		"skipped", usersSkipped,
+		"guests_skipped", guestsSkipped,
+	)
+
+	if a.servicePrincipalsEnabled {
+		servicePrincipals, err := a.getServicePrincipals(ctx)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, servicePrincipals...)
+	}
 	return users, nil
 }
 
 func (a *AzureReal) GetGroupsWithMembers() ([]SourceGroupWithMembers, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), a.fetchTimeout)
 	defer cancel()
 
+	directoryGroupIDs, err := a.getDirectoryGroupIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
 	groupsRaw, err := a.getGroupsWithMembersRaw(ctx, defaultGroupFieldsToSelect, a.groupsFilter)
 	if err != nil {
 		return nil, err
 	}
 
 	groupsSkipped := 0
+	groupsNotSelected := 0
+	ignoredMembers := newIgnoredMembersCounter()
 	var groups []SourceGroupWithMembers
+	// truncatedGroups are indexes of groups, which members should be fetched separately.
+	var truncatedGroups []int
 	for _, group := range groupsRaw {
 		displayName := handleNil(group.GetDisplayName())
 		id := handleNil(group.GetId())
@@ -179,29 +340,20 @@ func (a *AzureReal) GetGroupsWithMembers() ([]SourceGroupWithMembers, error) {
 			continue
 		}
 
-		if a.groupsDisplayNameSuffixPostFilter != "" && !strings.HasSuffix(displayName, a.groupsDisplayNameSuffixPostFilter) {
+		if !a.groupSelector.isSelected(id, displayName, directoryGroupIDs) {
+			a.maybePrintDebugLogs(id, "displayName", displayName, "selected", false)
+			groupsNotSelected++
 			continue
 		}
 
-		memberIDs := NewStringSet()
 		members := group.GetMembers()
+		memberIDs := NewStringSet()
 		if len(members) == msgraphExpandLimit {
 			// By default, $expand returns only 20 members, for those groups we collect all users by group id.
-			members, err = a.getGroupMembers(ctx, id)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to fetch all members")
-			}
-		}
-
-		for _, azureMember := range members {
-			azureUserID := azureMember.GetId()
-			if azureUserID == nil {
-				a.logger.Error("Empty group member id", "group", displayName)
-				continue
-			}
-			memberIDs.Add(*azureUserID)
+			truncatedGroups = append(truncatedGroups, len(groups))
+		} else {
+			memberIDs = a.getMemberIDs(displayName, members, ignoredMembers)
 		}
-		a.maybePrintDebugLogs(id, "azure_members_count", len(memberIDs.ToSlice()))
 
 		groups = append(groups,
 			SourceGroupWithMembers{
@@ -214,10 +366,104 @@ func (a *AzureReal) GetGroupsWithMembers() ([]SourceGroupWithMembers, error) {
 			})
 	}
 
-	a.logger.Infow("Fetched groups from Azure AD", "got", len(groupsRaw), "skipped", groupsSkipped)
+	err = a.fetchAllMembers(ctx, groups, truncatedGroups, ignoredMembers)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		a.maybePrintDebugLogs(group.SourceGroup.GetID(), "azure_members_count", group.Members.Cardinality())
+	}
+
+	a.logger.Infow("Fetched groups from Azure AD",
+		"got", len(groupsRaw),
+		"skipped", groupsSkipped,
+		"not_selected", groupsNotSelected,
+		"members_fetched_separately", len(truncatedGroups),
+		"ignored_members", ignoredMembers.get(),
+	)
 	return groups, nil
 }
 
+// getMemberIDs returns ids of members, which may be synced as YTsaurus users, other members are counted as ignored.
+func (a *AzureReal) getMemberIDs(
+	groupDisplayName string,
+	members []models.DirectoryObjectable,
+	ignoredMembers *ignoredMembersCounter,
+) StringSet {
+	memberIDs := NewStringSet()
+	for _, azureMember := range members {
+		azureUserID := azureMember.GetId()
+		if azureUserID == nil {
+			a.logger.Errorw("Empty group member id", "group", groupDisplayName)
+			continue
+		}
+		if odataType := handleNil(azureMember.GetOdataType()); !a.isSyncedMemberType(odataType) {
+			a.maybePrintDebugLogs(*azureUserID, "group", groupDisplayName, "ignoredMemberType", odataType)
+			ignoredMembers.add(odataType)
+			continue
+		}
+		memberIDs.Add(*azureUserID)
+	}
+	return memberIDs
+}
+
+// fetchAllMembers replaces members of the groups with the given indexes with all members fetched by group id.
+// Groups are fetched concurrently, at most membersFetchConcurrency at once. The first error stops the fetching.
+func (a *AzureReal) fetchAllMembers(
+	ctx context.Context,
+	groups []SourceGroupWithMembers,
+	indexes []int,
+	ignoredMembers *ignoredMembersCounter,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var fetchErr error
+	var fetchErrOnce sync.Once
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+
+	workers := a.membersFetchConcurrency
+	if workers > len(indexes) {
+		workers = len(indexes)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				// Every group is processed by a single worker, so groups are modified without locks.
+				group := &groups[idx]
+				members, err := a.getGroupMembers(ctx, group.SourceGroup.GetID())
+				if err != nil {
+					fetchErrOnce.Do(func() {
+						fetchErr = errors.Wrapf(err, "failed to fetch all members of group %s", group.SourceGroup.GetID())
+						cancel()
+					})
+					continue
+				}
+				group.Members = a.getMemberIDs(group.SourceGroup.GetName(), members, ignoredMembers)
+			}
+		}()
+	}
+
+loop:
+	for _, idx := range indexes {
+		select {
+		case jobs <- idx:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if fetchErr == nil && ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "failed to fetch all members")
+	}
+	return fetchErr
+}
+
 func (a *AzureReal) maybePrintDebugLogs(id ObjectID, args ...any) {
 	args = append([]any{"id", id}, args...)
 	for _, debugID := range a.debugAzureIDs {
@@ -258,7 +504,7 @@ func (a *AzureReal) getUsersRaw(ctx context.Context, fieldsToSelect []string, fi
 	}
 
 	var rawUsers []models.Userable
-	err = pageIterator.Iterate(context.Background(), func(user models.Userable) bool {
+	err = pageIterator.Iterate(ctx, func(user models.Userable) bool {
 		rawUsers = append(rawUsers, user)
 		// Return true to continue the iteration.
 		return true
@@ -299,7 +545,7 @@ func (a *AzureReal) getGroupsWithMembersRaw(ctx context.Context, fieldsToSelect
 	}
 
 	var rawGroups []models.Groupable
-	err = pageIterator.Iterate(context.Background(), func(group models.Groupable) bool {
+	err = pageIterator.Iterate(ctx, func(group models.Groupable) bool {
 		rawGroups = append(rawGroups, group)
 		// Return true to continue the iteration.
 		return true
@@ -337,7 +583,7 @@ func (a *AzureReal) getGroupMembers(ctx context.Context, groupID string) ([]mode
 	}
 
 	var rawMembers []models.DirectoryObjectable
-	err = pageIterator.Iterate(context.Background(), func(pageItem models.DirectoryObjectable) bool {
+	err = pageIterator.Iterate(ctx, func(pageItem models.DirectoryObjectable) bool {
 		rawMembers = append(rawMembers, pageItem)
 		// Return true to continue the iteration.
 		return true
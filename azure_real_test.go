@@ -0,0 +1,397 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ptr[T any](value T) *T {
+	return &value
+}
+
+func newAzureRealWithFakeServer(t *testing.T, server *AzureGraphFakeServer, cfg *AzureConfig) *AzureReal {
+	if cfg.Retry.InitialBackoff == 0 {
+		cfg.Retry.InitialBackoff = 10 * time.Millisecond
+	}
+	azure, err := server.newAzureReal(cfg, getDevelopmentLogger())
+	require.NoError(t, err)
+	return azure
+}
+
+func TestAzureRealGetUsers(t *testing.T) {
+	server := NewAzureGraphFakeServer()
+	defer server.Close()
+	server.setPageSize(2)
+
+	server.addUser(map[string]any{
+		"id":                "alice-id",
+		"userPrincipalName": "alice@acme.com",
+		"mail":              "alice@acme.com",
+		"givenName":         "Alice",
+		"surname":           "Henderson",
+		"displayName":       "Henderson, Alice (ACME)",
+		"accountEnabled":    true,
+		"jobTitle":          "Not selected",
+		"userType":          "Member",
+	})
+	server.addUser(map[string]any{
+		"id":                "bob-id",
+		"userPrincipalName": "bob@acme.com",
+		"accountEnabled":    false,
+		"userType":          "Member",
+	})
+	server.addUser(map[string]any{
+		"id":       "no-principal-name-id",
+		"userType": "Member",
+	})
+	server.addUser(map[string]any{
+		"id":                "guest-id",
+		"userPrincipalName": "guest@external.com",
+		"userType":          "Guest",
+	})
+
+	azure := newAzureRealWithFakeServer(t, server, &AzureConfig{UsersFilter: "userType eq 'Member'"})
+	users, err := azure.GetUsers()
+	require.NoError(t, err)
+	require.Equal(t, []SourceUser{
+		AzureUser{
+			PrincipalName: "alice@acme.com",
+			AzureID:       "alice-id",
+			Email:         "alice@acme.com",
+			FirstName:     "Alice",
+			LastName:      "Henderson",
+			DisplayName:   "Henderson, Alice (ACME)",
+		},
+		AzureUser{
+			PrincipalName:   "bob@acme.com",
+			AzureID:         "bob-id",
+			AccountDisabled: true,
+		},
+	}, users)
+	// Three filtered users with page size 2 are returned in two pages.
+	require.Equal(t, 2, server.getRequestsCount("/v1.0/users"))
+}
+
+func TestAzureRealGetGroupsWithMembers(t *testing.T) {
+	server := NewAzureGraphFakeServer()
+	defer server.Close()
+	server.setPageSize(1)
+
+	var bigGroupMembers []string
+	for i := 0; i < msgraphExpandLimit+5; i++ {
+		id := fmt.Sprintf("user-%02d", i)
+		server.addUser(map[string]any{"id": id, "userPrincipalName": id + "@acme.com"})
+		bigGroupMembers = append(bigGroupMembers, id)
+	}
+	server.addGroup(map[string]any{"id": "small-id", "displayName": "acme.small|all"}, "user-00", "user-01")
+	server.addGroup(map[string]any{"id": "big-id", "displayName": "acme.big|all"}, bigGroupMembers...)
+	server.addGroup(map[string]any{"id": "filtered-id", "displayName": "acme.filtered"}, "user-00")
+	server.addGroup(map[string]any{"id": "other-id", "displayName": "other|all"}, "user-00")
+
+	azure := newAzureRealWithFakeServer(t, server, &AzureConfig{
+		GroupsFilter:                      "startswith(displayName, 'acme.')",
+		GroupsDisplayNameSuffixPostFilter: "|all",
+	})
+	groups, err := azure.GetGroupsWithMembers()
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+
+	require.Equal(t, AzureGroup{
+		Identity:    "acme.small|all",
+		AzureID:     "small-id",
+		DisplayName: "acme.small|all",
+	}, groups[0].SourceGroup)
+	require.Equal(t, NewStringSetFromItems("user-00", "user-01"), groups[0].Members)
+
+	require.Equal(t, "big-id", groups[1].SourceGroup.GetID())
+	require.Equal(t, NewStringSetFromItems(bigGroupMembers...), groups[1].Members)
+	// Members of the big group don't fit in $expand and are fetched separately with paging.
+	require.Equal(t, len(bigGroupMembers), server.getRequestsCount("/v1.0/groups/big-id/members"))
+	require.Equal(t, 0, server.getRequestsCount("/v1.0/groups/small-id/members"))
+}
+
+func TestAzureRealThrottling(t *testing.T) {
+	server := NewAzureGraphFakeServer()
+	defer server.Close()
+	server.addUser(map[string]any{"id": "alice-id", "userPrincipalName": "alice@acme.com"})
+	server.addGroup(map[string]any{"id": "devs-id", "displayName": "devs"}, "alice-id")
+
+	server.throttleNextRequests(3, "0")
+	azure := newAzureRealWithFakeServer(t, server, &AzureConfig{})
+	users, err := azure.GetUsers()
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	require.Equal(t, 4, server.getRequestsCount("/v1.0/users"))
+
+	server.throttleNextRequests(2, "")
+	groups, err := azure.GetGroupsWithMembers()
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	require.Equal(t, 3, server.getRequestsCount("/v1.0/groups"))
+
+	server.throttleNextRequests(10, "0")
+	azure = newAzureRealWithFakeServer(t, server, &AzureConfig{Retry: AzureRetryConfig{MaxRetries: ptr(2)}})
+	_, err = azure.GetUsers()
+	require.Error(t, err)
+	require.Equal(t, 4+3, server.getRequestsCount("/v1.0/users"))
+
+	// Zero max retries disables retries.
+	server.throttleNextRequests(1, "0")
+	azure = newAzureRealWithFakeServer(t, server, &AzureConfig{Retry: AzureRetryConfig{MaxRetries: ptr(0)}})
+	_, err = azure.GetUsers()
+	require.Error(t, err)
+	require.Equal(t, 4+3+1, server.getRequestsCount("/v1.0/users"))
+}
+
+func TestAzureRealUnsupportedFilter(t *testing.T) {
+	server := NewAzureGraphFakeServer()
+	defer server.Close()
+
+	azure := newAzureRealWithFakeServer(t, server, &AzureConfig{UsersFilter: "userType in ('Member')"})
+	_, err := azure.GetUsers()
+	require.Error(t, err)
+}
+
+func TestAzureRealFetchMembersConcurrently(t *testing.T) {
+	const groupsCount = 10
+	for _, concurrency := range []int{1, 3} {
+		server := NewAzureGraphFakeServer()
+		server.setResponseDelay(20 * time.Millisecond)
+
+		var memberIDs []string
+		for i := 0; i < msgraphExpandLimit+groupsCount; i++ {
+			id := fmt.Sprintf("user-%02d", i)
+			server.addUser(map[string]any{"id": id, "userPrincipalName": id + "@acme.com"})
+			memberIDs = append(memberIDs, id)
+		}
+		for i := 0; i < groupsCount+1; i++ {
+			id := fmt.Sprintf("group-%02d", i)
+			// Every group has its own subset of members, the last one fits in $expand.
+			server.addGroup(map[string]any{"id": id, "displayName": id}, memberIDs[i+1:]...)
+		}
+
+		azure := newAzureRealWithFakeServer(t, server, &AzureConfig{MembersFetchConcurrency: concurrency})
+		groups, err := azure.GetGroupsWithMembers()
+		server.Close()
+		require.NoError(t, err)
+
+		require.Len(t, groups, groupsCount+1)
+		for i, group := range groups {
+			require.Equal(t, fmt.Sprintf("group-%02d", i), group.SourceGroup.GetID())
+			require.Equal(t, NewStringSetFromItems(memberIDs[i+1:]...), group.Members)
+		}
+		require.Equal(t, 0, server.getRequestsCount(fmt.Sprintf("/v1.0/groups/group-%02d/members", groupsCount)))
+		require.Equal(t, concurrency, server.getMaxConcurrentRequests())
+	}
+}
+
+func TestAzureRealFetchMembersError(t *testing.T) {
+	server := NewAzureGraphFakeServer()
+	defer server.Close()
+
+	var memberIDs []string
+	for i := 0; i < msgraphExpandLimit; i++ {
+		id := fmt.Sprintf("user-%02d", i)
+		server.addUser(map[string]any{"id": id, "userPrincipalName": id + "@acme.com"})
+		memberIDs = append(memberIDs, id)
+	}
+	server.addGroup(map[string]any{"id": "devs-id", "displayName": "devs"}, memberIDs...)
+	// Group members can't be fetched if the group is deleted in the middle of the fetch.
+	server.addGroup(map[string]any{"id": "ops-id", "displayName": "ops"}, memberIDs...)
+	server.markGroupDeleted("ops-id")
+
+	azure := newAzureRealWithFakeServer(t, server, &AzureConfig{})
+	_, err := azure.GetGroupsWithMembers()
+	require.ErrorContains(t, err, "failed to fetch all members of group ops-id")
+}
+
+func TestAzureRealInvalidMembersFetchConcurrency(t *testing.T) {
+	server := NewAzureGraphFakeServer()
+	defer server.Close()
+
+	_, err := server.newAzureReal(&AzureConfig{MembersFetchConcurrency: -1}, getDevelopmentLogger())
+	require.ErrorContains(t, err, "members_fetch_concurrency")
+}
+
+func TestAzureRealServicePrincipalsAndGuests(t *testing.T) {
+	server := NewAzureGraphFakeServer()
+	defer server.Close()
+
+	server.addUser(map[string]any{"id": "alice-id", "userPrincipalName": "alice@acme.com", "userType": "Member"})
+	server.addUser(map[string]any{
+		"id":                "guest-id",
+		"userPrincipalName": "bob_external.com#EXT#@acme.onmicrosoft.com",
+		"mail":              "bob@external.com",
+		"userType":          "Guest",
+	})
+	server.addServicePrincipal(map[string]any{
+		"id":             "robot-id",
+		"appId":          "robot-app-id",
+		"displayName":    "ci",
+		"accountEnabled": true,
+		"tags":           "ytsaurus",
+	})
+	server.addServicePrincipal(map[string]any{"id": "other-robot-id", "displayName": "other"})
+
+	azure := newAzureRealWithFakeServer(t, server, &AzureConfig{})
+	users, err := azure.GetUsers()
+	require.NoError(t, err)
+	// Guests and service principals are not synced by default.
+	require.Equal(t, []SourceUser{AzureUser{PrincipalName: "alice@acme.com", AzureID: "alice-id"}}, users)
+	require.Equal(t, 0, server.getRequestsCount("/v1.0/servicePrincipals"))
+
+	azure = newAzureRealWithFakeServer(t, server, &AzureConfig{
+		ServicePrincipals: AzureServicePrincipalsConfig{
+			Enabled:   true,
+			Filter:    "tags eq 'ytsaurus'",
+			NameField: azureNameFieldAppID,
+		},
+		Guests: AzureGuestsConfig{Enabled: true},
+	})
+	users, err = azure.GetUsers()
+	require.NoError(t, err)
+	require.Equal(t, []SourceUser{
+		AzureUser{PrincipalName: "alice@acme.com", AzureID: "alice-id"},
+		AzureUser{
+			PrincipalName: "bob_external.com#EXT#@acme.onmicrosoft.com",
+			Type:          azureUserTypeGuest,
+			Name:          "guest-bob@external.com",
+			AzureID:       "guest-id",
+			Email:         "bob@external.com",
+		},
+		AzureUser{
+			Type:        azureUserTypeServicePrincipal,
+			Name:        "robot-robot-app-id",
+			AppID:       "robot-app-id",
+			AzureID:     "robot-id",
+			DisplayName: "ci",
+		},
+	}, users)
+
+	// Naming scheme is a part of the source attribute, so it survives the roundtrip.
+	raw, err := users[2].GetRaw()
+	require.NoError(t, err)
+	restored, err := azure.CreateUserFromRaw(raw)
+	require.NoError(t, err)
+	require.Equal(t, "robot-robot-app-id", restored.GetName())
+
+	// Empty name prefix is not replaced with the default one.
+	azure = newAzureRealWithFakeServer(t, server, &AzureConfig{
+		ServicePrincipals: AzureServicePrincipalsConfig{
+			Enabled:    true,
+			Filter:     "tags eq 'ytsaurus'",
+			NameField:  azureNameFieldAppID,
+			NamePrefix: ptr(""),
+		},
+	})
+	users, err = azure.GetUsers()
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	require.Equal(t, "robot-app-id", users[1].GetName())
+
+	_, err = server.newAzureReal(&AzureConfig{Guests: AzureGuestsConfig{NameField: azureNameFieldAppID}}, getDevelopmentLogger())
+	require.ErrorContains(t, err, "invalid guests naming")
+}
+
+func TestAzureRealIgnoredMemberTypes(t *testing.T) {
+	server := NewAzureGraphFakeServer()
+	defer server.Close()
+
+	server.addUser(map[string]any{"id": "alice-id", "userPrincipalName": "alice@acme.com"})
+	server.addServicePrincipal(map[string]any{"id": "robot-id", "displayName": "ci"})
+	server.addDirectoryObject(map[string]any{"id": "device-id"}, graphODataTypeDevice)
+	server.addGroup(map[string]any{"id": "nested-id", "displayName": "nested"}, "alice-id")
+	server.addGroup(map[string]any{"id": "devs-id", "displayName": "devs"}, "alice-id", "robot-id", "device-id", "nested-id")
+
+	var bigGroupMembers []string
+	for i := 0; i < msgraphExpandLimit; i++ {
+		id := fmt.Sprintf("device-%02d", i)
+		server.addDirectoryObject(map[string]any{"id": id}, graphODataTypeDevice)
+		bigGroupMembers = append(bigGroupMembers, id)
+	}
+	server.addGroup(map[string]any{"id": "big-id", "displayName": "big"}, append(bigGroupMembers, "alice-id")...)
+
+	azure := newAzureRealWithFakeServer(t, server, &AzureConfig{})
+	groups, err := azure.GetGroupsWithMembers()
+	require.NoError(t, err)
+	require.Len(t, groups, 3)
+	require.Equal(t, NewStringSetFromItems("alice-id"), groups[1].Members)
+	require.Equal(t, NewStringSetFromItems("alice-id"), groups[2].Members)
+
+	azure = newAzureRealWithFakeServer(t, server, &AzureConfig{
+		ServicePrincipals: AzureServicePrincipalsConfig{Enabled: true},
+	})
+	groups, err = azure.GetGroupsWithMembers()
+	require.NoError(t, err)
+	require.Equal(t, NewStringSetFromItems("alice-id", "robot-id"), groups[1].Members)
+}
+
+func TestAzureRealGroupSelection(t *testing.T) {
+	server := NewAzureGraphFakeServer()
+	defer server.Close()
+
+	server.addUser(map[string]any{"id": "alice-id", "userPrincipalName": "alice@acme.com"})
+	for _, name := range []string{
+		"by-id", "team-by-prefix", "by-regex-42", "by-unit", "by-owner",
+		"not-selected", "team-excluded", "team-no-suffix",
+	} {
+		displayName := name + "|all"
+		if name == "team-no-suffix" {
+			displayName = name
+		}
+		server.addGroup(map[string]any{"id": name + "-id", "displayName": displayName}, "alice-id")
+	}
+	server.addAdministrativeUnit("unit-id", "by-unit-id", "alice-id")
+	server.setOwnedObjects("alice-id", "by-owner-id")
+
+	getGroupIDs := func(cfg *AzureConfig) []string {
+		azure := newAzureRealWithFakeServer(t, server, cfg)
+		groups, err := azure.GetGroupsWithMembers()
+		require.NoError(t, err)
+		var ids []string
+		for _, group := range groups {
+			ids = append(ids, group.SourceGroup.GetID())
+		}
+		return ids
+	}
+
+	// Without include rules all groups are selected.
+	require.Equal(t, []string{
+		"by-id-id", "team-by-prefix-id", "by-regex-42-id", "by-unit-id", "by-owner-id", "not-selected-id", "team-excluded-id",
+	}, getGroupIDs(&AzureConfig{GroupsDisplayNameSuffixPostFilter: "|all"}))
+
+	require.Equal(t, []string{
+		"by-id-id", "team-by-prefix-id", "by-regex-42-id", "by-unit-id", "by-owner-id",
+	}, getGroupIDs(&AzureConfig{
+		GroupsDisplayNameSuffixPostFilter: "|all",
+		GroupSelection: AzureGroupSelectionConfig{
+			IDs:                       []string{"by-id-id"},
+			DisplayNamePrefixes:       []string{"team-"},
+			IncludeDisplayNameRegexes: []string{`^by-regex-\d+`},
+			AdministrativeUnitIDs:     []string{"unit-id"},
+			Owners:                    []string{"alice@acme.com"},
+			ExcludeDisplayNameRegexes: []string{"excluded"},
+		},
+	}))
+
+	require.Equal(t, []string{"by-id-id", "by-unit-id"}, getGroupIDs(&AzureConfig{
+		GroupSelection: AzureGroupSelectionConfig{
+			IDs:                       []string{"by-id-id", "by-unit-id"},
+			ExcludeDisplayNameRegexes: []string{"^team-"},
+		},
+	}))
+
+	_, err := server.newAzureReal(&AzureConfig{
+		GroupSelection: AzureGroupSelectionConfig{IncludeDisplayNameRegexes: []string{"("}},
+	}, getDevelopmentLogger())
+	require.ErrorContains(t, err, "invalid include name regex")
+
+	azure := newAzureRealWithFakeServer(t, server, &AzureConfig{
+		GroupSelection: AzureGroupSelectionConfig{AdministrativeUnitIDs: []string{"missing-unit-id"}},
+	})
+	_, err = azure.GetGroupsWithMembers()
+	require.ErrorContains(t, err, "failed to get members of administrative unit missing-unit-id")
+}
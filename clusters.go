@@ -0,0 +1,212 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"k8s.io/utils/clock"
+)
+
+// Clusters syncs the source into all configured YTsaurus clusters. The source is fetched once per sync cycle,
+// diffs are calculated and applied for each cluster independently, so a failing cluster doesn't block others.
+type Clusters struct {
+	syncInterval time.Duration
+	source       Source
+	snapshot     *sourceSnapshot
+
+	names []string
+	apps  []*App
+
+	stopCh chan struct{}
+	sigCh  chan os.Signal
+	logger appLoggerType
+}
+
+func NewClusters(cfg *Config, logger appLoggerType) (*Clusters, error) {
+	if cfg.Azure == nil {
+		return nil, errors.New("one and only one source should be specified")
+	}
+
+	source, err := NewAzureReal(cfg.Azure, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return newClustersCustomized(cfg, logger, source, clock.RealClock{})
+}
+
+// newClustersCustomized used in tests.
+func newClustersCustomized(cfg *Config, logger appLoggerType, source Source, clock clock.PassiveClock) (*Clusters, error) {
+	if err := validateClustersConfig(cfg.Ytsaurus); err != nil {
+		return nil, err
+	}
+	var ytsauruses []*Ytsaurus
+	for i := range cfg.Ytsaurus {
+		clusterCfg := &cfg.Ytsaurus[i]
+		yt, err := NewYtsaurus(clusterCfg, logger.With("cluster", clusterName(clusterCfg)), clock)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cluster %s", clusterName(clusterCfg))
+		}
+		ytsauruses = append(ytsauruses, yt)
+	}
+	return newClustersWithYtsaurus(cfg, logger, source, ytsauruses, clock)
+}
+
+// newClustersWithYtsaurus used in tests with YtsaurusFake clients, ytsauruses are in the order of cfg.Ytsaurus.
+func newClustersWithYtsaurus(
+	cfg *Config,
+	logger appLoggerType,
+	source Source,
+	ytsauruses []*Ytsaurus,
+	clock clock.PassiveClock,
+) (*Clusters, error) {
+	if err := validateAppConfig(&cfg.App); err != nil {
+		return nil, err
+	}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR1)
+
+	clusters := &Clusters{
+		syncInterval: cfg.App.SyncInterval,
+		source:       source,
+		snapshot:     newSourceSnapshot(source),
+
+		stopCh: make(chan struct{}),
+		sigCh:  sigCh,
+		logger: logger,
+	}
+	for i, yt := range ytsauruses {
+		clusterCfg := &cfg.Ytsaurus[i]
+		name := clusterName(clusterCfg)
+		if len(cfg.App.ACLGrants) > 0 && clusterCfg.ACLGrantsStatePath == "" {
+			return nil, errors.Errorf("cluster %s: acl_grants require acl_grants_state_path, where paths with owned ACEs are recorded", name)
+		}
+		clusterSource, err := newClusterSource(clusters.snapshot, &clusterCfg.Filters)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cluster %s", name)
+		}
+		app := newAppWithYtsaurus(cfg, logger.With("cluster", name), clusterSource, yt, clock)
+		registerClusterMetrics(name, app.metrics)
+		clusters.names = append(clusters.names, name)
+		clusters.apps = append(clusters.apps, app)
+	}
+	return clusters, nil
+}
+
+func validateClustersConfig(clusters YtsaurusClustersConfig) error {
+	if len(clusters) == 0 {
+		return errors.New("at least one YTsaurus cluster should be specified")
+	}
+	names := NewStringSet()
+	for idx := range clusters {
+		name := clusterName(&clusters[idx])
+		if name == "" {
+			return errors.Errorf("cluster #%d: name or proxy is required", idx)
+		}
+		if names.Contains(name) {
+			return errors.Errorf("cluster #%d: duplicate cluster name %s", idx, name)
+		}
+		names.Add(name)
+		// YTsaurus client log level is set by YT_LOG_LEVEL, which is shared by all clusters.
+		if clusters[idx].LogLevel != clusters[0].LogLevel {
+			return errors.Errorf("cluster %s: log_level %q differs from %q, it should be the same for all clusters",
+				name, clusters[idx].LogLevel, clusters[0].LogLevel)
+		}
+	}
+	return nil
+}
+
+func clusterName(cfg *YtsaurusConfig) string {
+	if cfg.Name != "" {
+		return cfg.Name
+	}
+	return cfg.Proxy
+}
+
+func (c *Clusters) Start() {
+	runSyncLoop(c.syncInterval, c.stopCh, c.sigCh, c.logger, c.syncOnce)
+}
+
+func (c *Clusters) Stop() {
+	close(c.stopCh)
+}
+
+// syncOnce fetches the source and syncs clusters concurrently.
+func (c *Clusters) syncOnce() {
+	c.refreshSource()
+
+	var wg sync.WaitGroup
+	for idx := range c.apps {
+		wg.Add(1)
+		go func(name string, app *App) {
+			defer wg.Done()
+			// A panic in one cluster sync must not stop syncs of other clusters.
+			defer func() {
+				if r := recover(); r != nil {
+					c.logger.Errorw("cluster sync panicked", "cluster", name, "panic", r)
+				}
+			}()
+			app.syncOnce()
+		}(c.names[idx], c.apps[idx])
+	}
+	wg.Wait()
+}
+
+// refreshSource picks up the rotated source secret and fetches the source snapshot.
+func (c *Clusters) refreshSource() {
+	if reloader, ok := c.source.(secretReloader); ok {
+		if err := reloader.ReloadSecretIfChanged(); err != nil {
+			c.logger.Errorw("failed to reload source secret", zap.Error(err))
+		}
+	}
+	c.logger.Infow("Start fetching source", "clusters", len(c.apps))
+	c.snapshot.refresh()
+	c.logger.Infow("Finish fetching source",
+		"users", len(c.snapshot.users),
+		"groups", len(c.snapshot.groups),
+		"users_error", c.snapshot.usersErr,
+		"groups_error", c.snapshot.groupsErr,
+	)
+}
+
+// forEachCluster runs the action for every cluster, failed clusters don't stop the others.
+func (c *Clusters) forEachCluster(action func(app *App) error) error {
+	var failed []string
+	for idx, app := range c.apps {
+		if err := action(app); err != nil {
+			failed = append(failed, c.names[idx])
+			c.logger.Errorw("cluster action failed", zap.Error(err), "cluster", c.names[idx])
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("failed on clusters: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Adopt runs adoption for every cluster, see App.Adopt.
+func (c *Clusters) Adopt() error {
+	c.refreshSource()
+	return c.forEachCluster((*App).Adopt)
+}
+
+// BanUsers manually bans users in every cluster, see App.BanUsers.
+func (c *Clusters) BanUsers(usernames []string) error {
+	return c.forEachCluster(func(app *App) error {
+		return app.BanUsers(usernames)
+	})
+}
+
+// UnbanUsers lifts bans from users in every cluster, see App.UnbanUsers.
+func (c *Clusters) UnbanUsers(usernames []string) error {
+	return c.forEachCluster(func(app *App) error {
+		return app.UnbanUsers(usernames)
+	})
+}
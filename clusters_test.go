@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	testclock "k8s.io/utils/clock/testing"
+
+	"go.ytsaurus.tech/yt/go/ypath"
+	"go.ytsaurus.tech/yt/go/yt"
+)
+
+// countingSource counts fetches to check that the source is fetched once for all clusters.
+type countingSource struct {
+	Source
+	usersCalls  int
+	groupsCalls int
+}
+
+func (s *countingSource) GetUsers() ([]SourceUser, error) {
+	s.usersCalls++
+	return s.Source.GetUsers()
+}
+
+func (s *countingSource) GetGroupsWithMembers() ([]SourceGroupWithMembers, error) {
+	s.groupsCalls++
+	return s.Source.GetGroupsWithMembers()
+}
+
+// unavailableYtsaurusClient emulates the cluster outage.
+type unavailableYtsaurusClient struct {
+	ytsaurusClient
+}
+
+func (c unavailableYtsaurusClient) ListNode(ctx context.Context, path ypath.YPath, result any, options *yt.ListNodeOptions) error {
+	return errors.New("cluster is unavailable")
+}
+
+func TestClustersSync(t *testing.T) {
+	azure := NewAzureFake()
+	azure.setUsers([]SourceUser{aliceAzure, bobAzure})
+	azure.setGroups([]SourceGroupWithMembers{
+		{SourceGroup: devsAzureGroup, Members: NewStringSetFromItems(aliceAzure.AzureID)},
+		{SourceGroup: hqAzureGroup, Members: NewStringSetFromItems(bobAzure.AzureID)},
+	})
+	source := &countingSource{Source: azure}
+
+	cfg := newTestConfig(func(cfg *Config) {
+		cluster := func(name string) YtsaurusConfig {
+			clusterCfg := cfg.Ytsaurus[0]
+			clusterCfg.Name = name
+			return clusterCfg
+		}
+		filtered := cluster("filtered")
+		filtered.Filters = ClusterFiltersConfig{IncludeGroupNameRegexes: []string{`^acme\.hq`}}
+		cfg.Ytsaurus = YtsaurusClustersConfig{cluster("unavailable"), cluster("full"), filtered}
+		cfg.App.SyncOnlyGroupMembers = true
+	})
+
+	logger := getDevelopmentLogger()
+	clock := testclock.NewFakePassiveClock(initialTestTime)
+	clients := []ytsaurusClient{
+		unavailableYtsaurusClient{ytsaurusClient: NewYtsaurusFake()},
+		NewYtsaurusFake(),
+		NewYtsaurusFake(),
+	}
+	var ytsauruses []*Ytsaurus
+	for i, client := range clients {
+		ytsauruses = append(ytsauruses, newYtsaurusWithClient(&cfg.Ytsaurus[i], client, logger, clock))
+	}
+	clusters, err := newClustersWithYtsaurus(cfg, logger, source, ytsauruses, clock)
+	require.NoError(t, err)
+
+	clusters.syncOnce()
+	require.Equal(t, 1, source.usersCalls)
+	require.Equal(t, 1, source.groupsCalls)
+
+	getState := func(ytsaurus *Ytsaurus) (usernames []string, groupMembers map[string]StringSet) {
+		users, err := ytsaurus.GetUsers()
+		require.NoError(t, err)
+		for _, user := range users {
+			usernames = append(usernames, user.Username)
+		}
+		groups, err := ytsaurus.GetGroupsWithMembers()
+		require.NoError(t, err)
+		groupMembers = make(map[string]StringSet)
+		for _, group := range groups {
+			groupMembers[group.Name] = group.Members
+		}
+		return usernames, groupMembers
+	}
+
+	// The unavailable cluster doesn't block others.
+	usernames, groupMembers := getState(ytsauruses[1])
+	require.ElementsMatch(t, []string{"alice", "bob"}, usernames)
+	require.Equal(t, map[string]StringSet{
+		"acme.devs": NewStringSetFromItems("alice"),
+		"acme.hq":   NewStringSetFromItems("bob"),
+	}, groupMembers)
+
+	// Only filtered groups and their members are synced.
+	usernames, groupMembers = getState(ytsauruses[2])
+	require.Equal(t, []string{"bob"}, usernames)
+	require.Equal(t, map[string]StringSet{
+		"acme.hq": NewStringSetFromItems("bob"),
+	}, groupMembers)
+
+	// Paths of acl grants can't be recorded without the state path.
+	cfg.App.ACLGrants = []ACLGrantConfig{{Group: "acme.devs", Path: "//home/devs", Permissions: []string{"read"}}}
+	_, err = newClustersWithYtsaurus(cfg, logger, source, ytsauruses, clock)
+	require.ErrorContains(t, err, "acl_grants_state_path")
+}
+
+func TestValidateClustersConfig(t *testing.T) {
+	require.NoError(t, validateClustersConfig(YtsaurusClustersConfig{{Proxy: "hahn"}, {Name: "arnold", Proxy: "hahn"}}))
+	require.Error(t, validateClustersConfig(nil))
+	require.Error(t, validateClustersConfig(YtsaurusClustersConfig{{}}))
+	require.Error(t, validateClustersConfig(YtsaurusClustersConfig{{Proxy: "hahn"}, {Proxy: "hahn"}}))
+	require.NoError(t, validateClustersConfig(YtsaurusClustersConfig{
+		{Proxy: "hahn", LogLevel: "DEBUG"},
+		{Proxy: "arnold", LogLevel: "DEBUG"},
+	}))
+	require.ErrorContains(t, validateClustersConfig(YtsaurusClustersConfig{
+		{Proxy: "hahn", LogLevel: "DEBUG"},
+		{Proxy: "arnold"},
+	}), "log_level")
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	App      AppConfig      `yaml:"app"`
-	Ytsaurus YtsaurusConfig `yaml:"ytsaurus"`
-	Logging  LoggingConfig  `yaml:"logging"`
+	App AppConfig `yaml:"app"`
+	// Ytsaurus is a list of clusters synced from the same source, a single cluster may be specified as a mapping.
+	Ytsaurus YtsaurusClustersConfig `yaml:"ytsaurus"`
+	Logging  LoggingConfig          `yaml:"logging"`
 
 	Azure *AzureConfig `yaml:"azure,omitempty"`
 }
@@ -23,6 +26,9 @@ type AppConfig struct {
 	UsernameReplacements  []ReplacementPair `yaml:"username_replacements"`
 	GroupnameReplacements []ReplacementPair `yaml:"groupname_replacements"`
 
+	// Namespace isolates names of synced subjects from builtin and manually created ones, see NamespaceConfig.
+	Namespace NamespaceConfig `yaml:"namespace"`
+
 	// If count users or groups for planned delete in on sync cycle reaches RemoveLimit
 	// app will fail that sync cycle.
 	// No limit if it is not specified.
@@ -31,6 +37,86 @@ type AppConfig struct {
 	// BanBeforeRemoveDuration is a duration of a graceful ban before finally removing the user from YTsaurus.
 	// If it is not specified, user will be removed straight after user was found to be missing from source (Azure).
 	BanBeforeRemoveDuration time.Duration `yaml:"ban_before_remove_duration"`
+
+	// AdoptUnmanaged enables adoption step before each sync: unmanaged YTsaurus users and groups
+	// with the same name as computed for source objects get source attribute and become managed.
+	// The same can be done once with --adopt command line flag.
+	AdoptUnmanaged bool `yaml:"adopt_unmanaged"`
+
+	// UserRenamePolicy is one of: allow (default), forbid, notify. See UserRenamePolicy for details.
+	UserRenamePolicy UserRenamePolicy `yaml:"user_rename_policy"`
+
+	// SyncOnlyGroupMembers limits synced users to members of at least one synced group and ExtraUsers.
+	// Other source users are not created, and existing ones are banned (removed) with excluded_by_filter reason.
+	SyncOnlyGroupMembers bool `yaml:"sync_only_group_members"`
+	// ExtraUsers is a list of source user ids or names (before username replacements),
+	// which are synced regardless of group membership if SyncOnlyGroupMembers is set.
+	ExtraUsers []string `yaml:"extra_users"`
+
+	// GroupBindings feed membership of existing manually created YTsaurus groups from source groups.
+	// Bound groups are never created, renamed, removed or adopted, and bound source groups are not synced as managed groups.
+	GroupBindings []GroupBindingConfig `yaml:"group_bindings"`
+
+	// UnmanagedMembers configures what happens with manually managed users (and nested groups),
+	// which are added to synced groups by hand.
+	UnmanagedMembers UnmanagedMembersConfig `yaml:"unmanaged_members"`
+
+	// ACLGrants are ACEs for managed groups, which are reconciled on every sync cycle.
+	// Only ACEs added by the app are changed, manually added ACEs are left untouched.
+	// Paths with added ACEs are recorded in acl_grants_state_path, so it is required for every cluster.
+	ACLGrants []ACLGrantConfig `yaml:"acl_grants"`
+
+	// Notifications configures delivery of notable sync events (e.g. user reactivation).
+	// If it is not specified, events are only written to the log.
+	Notifications NotificationsConfig `yaml:"notifications"`
+
+	// MetricsAddress is an address (e.g. :9100), where sync counters are served at /debug/vars in expvar format.
+	// Metrics are not served if it is not specified.
+	MetricsAddress string `yaml:"metrics_address"`
+}
+
+type NamespaceConfig struct {
+	// GroupPrefix and GroupSuffix are added to names of synced groups after groupname replacements,
+	// e.g. Azure group admins becomes azure-admins with group_prefix "azure-".
+	// Groups with names outside the namespace are neither created nor adopted.
+	GroupPrefix string `yaml:"group_prefix"`
+	GroupSuffix string `yaml:"group_suffix"`
+	// UserPrefix and UserSuffix are added to usernames of synced users after username replacements.
+	UserPrefix string `yaml:"user_prefix"`
+	UserSuffix string `yaml:"user_suffix"`
+}
+
+type GroupBindingConfig struct {
+	// SourceGroupID is a source id of the group, it should be selected by the source group filters.
+	SourceGroupID string `yaml:"source_group_id"`
+	// Group is a name of the existing unmanaged YTsaurus group.
+	Group string `yaml:"group"`
+}
+
+type UnmanagedMembersConfig struct {
+	// Policy is one of: preserve (default), remove, report. See UnmanagedMembersPolicy for details.
+	Policy UnmanagedMembersPolicy `yaml:"policy"`
+	// Groups overrides the policy for particular groups, keys are YTsaurus group names or source group ids.
+	Groups map[string]UnmanagedMembersPolicy `yaml:"groups"`
+}
+
+type ACLGrantConfig struct {
+	// Group is a name of the managed YTsaurus group (after groupname replacements).
+	Group string `yaml:"group"`
+	// SourceGroupID is a source id of the group, it may be used instead of Group, so the grant survives group renames.
+	SourceGroupID string `yaml:"source_group_id"`
+	// Path is a Cypress path of the node, which ACL is reconciled.
+	Path string `yaml:"path"`
+	// Permissions are allowed to the group, e.g. read, write, remove, administer.
+	Permissions []string `yaml:"permissions"`
+	// Inheritance is one of: object_and_descendants (default), object_only, descendants_only, immediate_descendants_only.
+	Inheritance string `yaml:"inheritance"`
+}
+
+type NotificationsConfig struct {
+	// WebhookURL is an URL which receives POST requests with JSON encoded events.
+	WebhookURL string        `yaml:"webhook_url"`
+	Timeout    time.Duration `yaml:"timeout"`
 }
 
 type ReplacementPair struct {
@@ -39,29 +125,145 @@ type ReplacementPair struct {
 }
 
 type AzureConfig struct {
-	Tenant             string `yaml:"tenant"`
-	ClientID           string `yaml:"client_id"`
+	Tenant   string `yaml:"tenant"`
+	ClientID string `yaml:"client_id"`
+
+	// AuthMethod is one of: client_secret (default), client_certificate, managed_identity, workload_identity.
+	AuthMethod         string `yaml:"auth_method"`
 	ClientSecretEnvVar string `yaml:"client_secret_env_var"` // default: "AZURE_CLIENT_SECRET"
+	// ClientSecretFile is a path to the file with client secret, it has priority over ClientSecretEnvVar.
+	// The file is re-read before each sync, so the secret can be rotated without restart.
+	ClientSecretFile string `yaml:"client_secret_file"`
+	// ClientCertificatePath is a path to PEM or PFX file with the certificate and its private key.
+	ClientCertificatePath string `yaml:"client_certificate_path"`
+	// ClientCertificatePasswordEnvVar is a name of env variable with the certificate password (if it is encrypted).
+	ClientCertificatePasswordEnvVar string `yaml:"client_certificate_password_env_var"`
+	// ManagedIdentityClientID is a client ID of the user-assigned managed identity.
+	// System-assigned identity is used if it is not specified.
+	ManagedIdentityClientID string `yaml:"managed_identity_client_id"`
+	// FederatedTokenFile is a path to the Kubernetes service account token for the workload identity.
+	// Default: value of AZURE_FEDERATED_TOKEN_FILE env var (set by AKS workload identity webhook).
+	FederatedTokenFile string `yaml:"federated_token_file"`
 
 	// UsersFilter is MS Graph $filter value used for user fetching requests.
 	// See https://learn.microsoft.com/en-us/graph/api/user-list?#optional-query-parameters
+	// It should not filter out disabled accounts (accountEnabled eq true): users with disabled accounts are banned,
+	// while users missing from the source are removed (after BanBeforeRemoveDuration).
 	UsersFilter string `yaml:"users_filter"`
 	// GroupsFilter is MS Graph $filter value used for group fetching requests.
 	// See https://learn.microsoft.com/en-us/graph/api/group-list
 	GroupsFilter string `yaml:"groups_filter"`
 
 	// GroupsDisplayNameSuffixPostFilter applied to the fetched groups display names.
-	GroupsDisplayNameSuffixPostFilter string        `yaml:"groups_display_name_suffix_post_filter"`
-	Timeout                           time.Duration `yaml:"timeout"`
+	GroupsDisplayNameSuffixPostFilter string `yaml:"groups_display_name_suffix_post_filter"`
+	// GroupSelection narrows down groups fetched with GroupsFilter.
+	GroupSelection AzureGroupSelectionConfig `yaml:"group_selection"`
+
+	// Timeout is a timeout of a single MS Graph request (e.g. one page), every retry has its own timeout.
+	Timeout time.Duration `yaml:"timeout"`
+	// FetchTimeout is an overall deadline for fetching all users or all groups with members, including retries.
+	FetchTimeout time.Duration `yaml:"fetch_timeout"`
+	// Retry configures retries of throttled and temporarily failed MS Graph requests.
+	Retry AzureRetryConfig `yaml:"retry"`
+	// MembersFetchConcurrency is a maximum number of groups, which members are fetched concurrently.
+	// Members are fetched separately only for groups with more members than $expand returns. Default: 8.
+	MembersFetchConcurrency int `yaml:"members_fetch_concurrency"`
+
+	// ServicePrincipals configures sync of service principals (e.g. robot accounts) as YTsaurus users.
+	ServicePrincipals AzureServicePrincipalsConfig `yaml:"service_principals"`
+	// Guests configures sync of guest users (userType Guest), they are skipped unless enabled.
+	Guests AzureGuestsConfig `yaml:"guests"`
 
 	// DebugAzureIDs is a list of ids for which app will print more debug info in logs.
 	DebugAzureIDs []string `yaml:"debug_azure_ids"`
 }
 
+// AzureGroupSelectionConfig is applied to groups fetched with GroupsFilter and suffix post filter.
+// A group is selected if it matches any of include rules (or there are no include rules at all)
+// and doesn't match any of exclude rules.
+type AzureGroupSelectionConfig struct {
+	// IDs is a list of Azure group ids.
+	IDs []string `yaml:"ids"`
+	// DisplayNamePrefixes is a list of group display name prefixes.
+	DisplayNamePrefixes []string `yaml:"display_name_prefixes"`
+	// IncludeDisplayNameRegexes is a list of regexes, group display name should match any of them.
+	IncludeDisplayNameRegexes []string `yaml:"include_display_name_regexes"`
+	// AdministrativeUnitIDs is a list of Azure administrative units ids, groups members of them are selected.
+	AdministrativeUnitIDs []string `yaml:"administrative_unit_ids"`
+	// Owners is a list of user ids or principal names, groups owned by them are selected.
+	Owners []string `yaml:"owners"`
+
+	// ExcludeDisplayNameRegexes is a list of regexes, groups with display name matching any of them are not selected.
+	ExcludeDisplayNameRegexes []string `yaml:"exclude_display_name_regexes"`
+}
+
+type AzureServicePrincipalsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Filter is MS Graph $filter value used for service principals fetching requests.
+	// See https://learn.microsoft.com/en-us/graph/api/serviceprincipal-list
+	Filter string `yaml:"filter"`
+	// NameField is one of: display_name (default), app_id.
+	// Display names are not unique in Azure, app_id should be used if it is not guaranteed by other means.
+	NameField string `yaml:"name_field"`
+	// NamePrefix is prepended to the name field value before username replacements. Default: "robot-".
+	// Empty string disables the prefix, then names may collide with usernames of regular users.
+	NamePrefix *string `yaml:"name_prefix"`
+}
+
+type AzureGuestsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// NameField is one of: email (default), principal_name, display_name.
+	// Guest principal names look like alice_external.com#EXT#@acme.onmicrosoft.com, so email is used by default.
+	NameField string `yaml:"name_field"`
+	// NamePrefix is prepended to the name field value before username replacements. Default: "guest-".
+	// Empty string disables the prefix, then names may collide with usernames of regular users.
+	NamePrefix *string `yaml:"name_prefix"`
+}
+
+type AzureRetryConfig struct {
+	// MaxRetries is a maximum number of retries of a single request, 0 disables retries. Default: 5.
+	MaxRetries *int `yaml:"max_retries"`
+	// InitialBackoff is a delay before the first retry, it is doubled for every next retry.
+	// Retry-After header value has priority over the backoff. Default: 1s.
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	// MaxBackoff limits exponential backoff delay. Default: 1m.
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+}
+
+// YtsaurusClustersConfig is decoded from a list of clusters or from a mapping of the single cluster.
+type YtsaurusClustersConfig []YtsaurusConfig
+
+func (c *YtsaurusClustersConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		var cluster YtsaurusConfig
+		if err := value.Decode(&cluster); err != nil {
+			return err
+		}
+		*c = YtsaurusClustersConfig{cluster}
+		return nil
+	}
+	var clusters []YtsaurusConfig
+	if err := value.Decode(&clusters); err != nil {
+		return err
+	}
+	*c = clusters
+	return nil
+}
+
 type YtsaurusConfig struct {
+	// Name is used in logs to tell clusters apart. Default: Proxy.
+	Name  string `yaml:"name"`
 	Proxy string `yaml:"proxy"`
+	// Transport is one of: http (default), rpc. RPC proxies are discovered via Proxy, unless RPCProxy is set.
+	// Credentials and timeouts are the same for both transports.
+	Transport YtsaurusTransport `yaml:"transport"`
+	// RPCProxy pins the address of the RPC proxy, it is used only with rpc transport.
+	RPCProxy string `yaml:"rpc_proxy"`
 	// SecretEnvVar is a name of env variable with YTsaurus token. Default: "YT_TOKEN".
 	SecretEnvVar string `yaml:"secret_env_var"`
+	// SecretFile is a path to the file with YTsaurus token, it has priority over SecretEnvVar.
+	// The file is re-read before each sync, so the token can be rotated without restart.
+	SecretFile string `yaml:"secret_file"`
 	// ApplyUserChanges = false means dry-run (no writes will be executed) for users updates.
 	ApplyUserChanges bool `yaml:"apply_user_changes"`
 	// ApplyGroupChanges = false means dry-run (no writes will be executed) for groups updates.
@@ -69,8 +271,9 @@ type YtsaurusConfig struct {
 	// ApplyMemberChanges = false means dry-run (no writes will be executed) for membership updates.
 	ApplyMemberChanges bool `yaml:"apply_member_changes"`
 
-	Timeout  time.Duration `yaml:"timeout"`
-	LogLevel string        `yaml:"log_level"`
+	Timeout time.Duration `yaml:"timeout"`
+	// LogLevel of YTsaurus client is process wide (YT_LOG_LEVEL), so it should be the same for all clusters.
+	LogLevel string `yaml:"log_level"`
 
 	// DebugUsernames is a list of YTsaurus usernames for which app will print more debug info in logs.
 	DebugUsernames []string `yaml:"debug_usernames"`
@@ -78,6 +281,113 @@ type YtsaurusConfig struct {
 	DebugGroupnames []string `yaml:"debug_groupnames"`
 	// The attribute name of user/group object in YTsaurus.
 	SourceAttributeName string `yaml:"source_attribute_name"`
+
+	// MembershipsStorePath is a Cypress path where group memberships of users are recorded at ban time.
+	// If the user returns to the source after removal, memberships in existing manually managed groups
+	// are restored from the record (managed groups' memberships are synced from the source anyway).
+	// Memberships are not recorded if it is not specified.
+	MembershipsStorePath string `yaml:"memberships_store_path"`
+	// ACLGrantsStatePath is a Cypress path of the document, where paths of nodes with ACEs added by app.acl_grants
+	// are recorded, so the ACEs are revoked after their paths are removed from the config.
+	ACLGrantsStatePath string `yaml:"acl_grants_state_path"`
+
+	// UserAttributes configures well-known user attributes, which are populated from the source.
+	UserAttributes UserAttributesConfig `yaml:"user_attributes"`
+
+	// HomeDirectories configures provisioning of home directories for created users.
+	HomeDirectories HomeDirectoriesConfig `yaml:"home_directories"`
+	// GroupAccounts configures provisioning of accounts for managed groups.
+	GroupAccounts GroupAccountsConfig `yaml:"group_accounts"`
+	// GroupPools configures provisioning of scheduler pools for managed groups.
+	GroupPools GroupPoolsConfig `yaml:"group_pools"`
+
+	// SourceAttributeMigration configures migration of the source attribute from the legacy name,
+	// which is launched once with --migrate-source-attribute command line flag.
+	SourceAttributeMigration SourceAttributeMigrationConfig `yaml:"source_attribute_migration"`
+
+	// Filters narrow down source groups synced into the cluster.
+	Filters ClusterFiltersConfig `yaml:"filters"`
+}
+
+// ClusterFiltersConfig is applied to the source groups fetched once for all clusters.
+// Users are not filtered, set app.sync_only_group_members to sync only members of the cluster groups.
+type ClusterFiltersConfig struct {
+	// GroupIDs is a list of source group ids synced into the cluster.
+	GroupIDs []string `yaml:"group_ids"`
+	// IncludeGroupNameRegexes select groups by source names (before groupname replacements).
+	// Groups are selected if they match either GroupIDs or any of regexes. All groups are selected if both are empty.
+	IncludeGroupNameRegexes []string `yaml:"include_group_name_regexes"`
+	// ExcludeGroupNameRegexes are applied after the include rules.
+	ExcludeGroupNameRegexes []string `yaml:"exclude_group_name_regexes"`
+}
+
+// UserAttributesConfig contains names of well-known YTsaurus user attributes read by the UI and notifications.
+// Attributes are not populated if their names are not specified.
+type UserAttributesConfig struct {
+	// EmailAttributeName is a name of the attribute for the user email, for example "email".
+	EmailAttributeName string `yaml:"email_attribute_name"`
+	// FullNameAttributeName is a name of the attribute for the user full name (Azure display name), for example "full_name".
+	FullNameAttributeName string `yaml:"full_name_attribute_name"`
+}
+
+type HomeDirectoriesConfig struct {
+	// PathTemplate is a Cypress path of the user home directory, {username} is replaced with the username,
+	// for example "//home/{username}". Home directories are not provisioned if it is not specified.
+	PathTemplate string `yaml:"path_template"`
+	// Permissions are granted to the user on the home directory. Default: read, write, remove, administer.
+	Permissions []string `yaml:"permissions"`
+	// OnUserRemoval is one of: keep (default), archive.
+	// Kept home directory isn't given to a new user with the same name, it is reported on the user creation.
+	OnUserRemoval HomeDirectoryRemovalPolicy `yaml:"on_user_removal"`
+	// ArchivePathTemplate is a Cypress path where home directory is moved on user removal with archive policy,
+	// {username} and {cycle_id} are replaced, for example "//archive/home/{username}-{cycle_id}".
+	ArchivePathTemplate string `yaml:"archive_path_template"`
+}
+
+type GroupAccountsConfig struct {
+	// Enabled = true means an account with the same name is created for every managed group,
+	// and the group is granted `use` permission on it.
+	Enabled bool `yaml:"enabled"`
+	// ParentName is a name of the existing account, group accounts are created under it.
+	// Group accounts are top-level if it is not specified.
+	ParentName string `yaml:"parent_name"`
+	// ResourceLimits is @resource_limits of created accounts (e.g. node_count, chunk_count, disk_space_per_medium).
+	// Limits are set only on account creation, so limits tuned by admins are not overwritten.
+	ResourceLimits map[string]any `yaml:"resource_limits"`
+	// OnGroupRemoval is one of: keep (default), remove.
+	OnGroupRemoval GroupAccountRemovalPolicy `yaml:"on_group_removal"`
+}
+
+type GroupPoolsConfig struct {
+	// Enabled = true means a scheduler pool is created for every managed group,
+	// and the group is granted `use` permission on it.
+	Enabled bool `yaml:"enabled"`
+	// PoolTree is a name of the pool tree, where group pools are created. Default: "default".
+	PoolTree string `yaml:"pool_tree"`
+	// ParentPoolPath is a path of the existing parent pool inside the pool tree, for example "research/teams".
+	// Group pools are created in the root of the pool tree if it is not specified.
+	ParentPoolPath string `yaml:"parent_pool_path"`
+	// NameReplacements are applied to the group name to build the pool name,
+	// since pool names may contain only latin letters, digits, "-" and "_".
+	NameReplacements []ReplacementPair `yaml:"name_replacements"`
+	// Attributes are set on pool creation, for example strong_guarantee_resources, weight or mode.
+	// They are not updated afterwards, so guarantees tuned by admins are not overwritten.
+	Attributes map[string]any `yaml:"attributes"`
+	// OnGroupRemoval is one of: detach (default), remove.
+	// Detached pool loses the source attribute and is not managed anymore.
+	OnGroupRemoval GroupPoolRemovalPolicy `yaml:"on_group_removal"`
+}
+
+type SourceAttributeMigrationConfig struct {
+	// LegacyAttributeName is the source attribute name used by older deployments (for example "azure").
+	LegacyAttributeName string `yaml:"legacy_attribute_name"`
+	// RemoveLegacyAttribute = true means legacy attribute is removed after it was copied to the new one.
+	RemoveLegacyAttribute bool `yaml:"remove_legacy_attribute"`
+	// ApplyChanges = false means dry-run (no writes will be executed) for migration.
+	ApplyChanges bool `yaml:"apply_changes"`
+	// Limit is a maximum number of users and groups changed in one migration launch.
+	// No limit if it is not specified.
+	Limit int `yaml:"limit"`
 }
 
 type LoggingConfig struct {
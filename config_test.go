@@ -25,22 +25,102 @@ func TestAzureConfig(t *testing.T) {
 	require.Equal(t, []ReplacementPair{
 		{From: "|all", To: ""},
 	}, cfg.App.GroupnameReplacements)
+	require.Equal(t, NamespaceConfig{GroupPrefix: "azure-"}, cfg.App.Namespace)
 	require.Equal(t, 10, cfg.App.RemoveLimit)
 	require.Equal(t, 7*24*time.Hour, cfg.App.BanBeforeRemoveDuration)
+	require.Equal(t, true, cfg.App.AdoptUnmanaged)
+	require.Equal(t, UserRenamePolicyNotify, cfg.App.UserRenamePolicy)
+	require.Equal(t, true, cfg.App.SyncOnlyGroupMembers)
+	require.Equal(t, []string{"yt-admin@acme.com"}, cfg.App.ExtraUsers)
+	require.Equal(t, []ACLGrantConfig{
+		{Group: "azure-acme.devs", Path: "//home/devs", Permissions: []string{"read", "write"}},
+		{SourceGroupID: "fake-az-acme.hq", Path: "//home/hq", Permissions: []string{"read"}, Inheritance: "object_only"},
+	}, cfg.App.ACLGrants)
+	require.Equal(t, []GroupBindingConfig{
+		{SourceGroupID: "fake-az-acme.legacy", Group: "legacy-admins"},
+	}, cfg.App.GroupBindings)
+	require.Equal(t, UnmanagedMembersConfig{
+		Policy: UnmanagedMembersPolicyReport,
+		Groups: map[string]UnmanagedMembersPolicy{"azure-acme.devs": UnmanagedMembersPolicyRemove},
+	}, cfg.App.UnmanagedMembers)
+	require.Equal(t, "https://hooks.acme.com/ytsaurus-ad-sync", cfg.App.Notifications.WebhookURL)
+	require.Equal(t, 5*time.Second, cfg.App.Notifications.Timeout)
 
 	require.Equal(t, "acme.onmicrosoft.com", cfg.Azure.Tenant)
 	require.Equal(t, "abcdefgh-a000-b111-c222-abcdef123456", cfg.Azure.ClientID)
+	require.Equal(t, "client_secret", cfg.Azure.AuthMethod)
+	require.Equal(t, "/etc/ytsaurus-ad-sync/azure-client-secret", cfg.Azure.ClientSecretFile)
 	require.Equal(t, 1*time.Second, cfg.Azure.Timeout)
-	require.Equal(t, "(accountEnabled eq true) and (userType eq 'Member')", cfg.Azure.UsersFilter)
+	require.Equal(t, 5*time.Minute, cfg.Azure.FetchTimeout)
+	require.Equal(t, AzureRetryConfig{
+		MaxRetries:     ptr(3),
+		InitialBackoff: 2 * time.Second,
+		MaxBackoff:     30 * time.Second,
+	}, cfg.Azure.Retry)
+	require.Equal(t, 4, cfg.Azure.MembersFetchConcurrency)
+	require.Equal(t, "userType eq 'Member'", cfg.Azure.UsersFilter)
 	require.Equal(t, "displayName -ne ''", cfg.Azure.GroupsFilter)
 	require.Equal(t, ".dev", cfg.Azure.GroupsDisplayNameSuffixPostFilter)
+	require.Equal(t, AzureGroupSelectionConfig{
+		IDs:                       []string{"a1b2c3d4-0000-1111-2222-abcdef123456"},
+		DisplayNamePrefixes:       []string{"yt-"},
+		IncludeDisplayNameRegexes: []string{`^team-[a-z]+\.dev$`},
+		AdministrativeUnitIDs:     []string{"d4c3b2a1-0000-1111-2222-abcdef123456"},
+		Owners:                    []string{"yt-admin@acme.com"},
+		ExcludeDisplayNameRegexes: []string{"-deprecated"},
+	}, cfg.Azure.GroupSelection)
+	require.Equal(t, AzureServicePrincipalsConfig{
+		Enabled:    true,
+		Filter:     "tags/any(t:t eq 'ytsaurus')",
+		NameField:  "app_id",
+		NamePrefix: ptr("robot-"),
+	}, cfg.Azure.ServicePrincipals)
+	require.Equal(t, AzureGuestsConfig{}, cfg.Azure.Guests)
 
-	require.Equal(t, "localhost:10110", cfg.Ytsaurus.Proxy)
-	require.Equal(t, true, cfg.Ytsaurus.ApplyUserChanges)
-	require.Equal(t, true, cfg.Ytsaurus.ApplyGroupChanges)
-	require.Equal(t, true, cfg.Ytsaurus.ApplyMemberChanges)
-	require.Equal(t, 1*time.Second, cfg.Ytsaurus.Timeout)
-	require.Equal(t, "DEBUG", cfg.Ytsaurus.LogLevel)
+	require.Equal(t, "localhost:10110", cfg.Ytsaurus[0].Proxy)
+	require.Equal(t, YtsaurusTransportHTTP, cfg.Ytsaurus[0].Transport)
+	require.Equal(t, "", cfg.Ytsaurus[0].RPCProxy)
+	require.Equal(t, "/etc/ytsaurus-ad-sync/yt-token", cfg.Ytsaurus[0].SecretFile)
+	require.Equal(t, true, cfg.Ytsaurus[0].ApplyUserChanges)
+	require.Equal(t, true, cfg.Ytsaurus[0].ApplyGroupChanges)
+	require.Equal(t, true, cfg.Ytsaurus[0].ApplyMemberChanges)
+	require.Equal(t, 1*time.Second, cfg.Ytsaurus[0].Timeout)
+	require.Equal(t, "DEBUG", cfg.Ytsaurus[0].LogLevel)
+	require.Equal(t, "//sys/ad_sync/memberships", cfg.Ytsaurus[0].MembershipsStorePath)
+	require.Equal(t, "//sys/ad_sync/acl_grants", cfg.Ytsaurus[0].ACLGrantsStatePath)
+	require.Equal(t, UserAttributesConfig{
+		EmailAttributeName:    "email",
+		FullNameAttributeName: "full_name",
+	}, cfg.Ytsaurus[0].UserAttributes)
+	require.Equal(t, HomeDirectoriesConfig{
+		PathTemplate:        "//home/{username}",
+		Permissions:         []string{"read", "write", "remove"},
+		OnUserRemoval:       HomeDirectoryRemovalPolicyArchive,
+		ArchivePathTemplate: "//archive/home/{username}-{cycle_id}",
+	}, cfg.Ytsaurus[0].HomeDirectories)
+	require.Equal(t, GroupAccountsConfig{
+		Enabled:    true,
+		ParentName: "groups",
+		ResourceLimits: map[string]any{
+			"node_count":  1000,
+			"chunk_count": 10000,
+			"disk_space_per_medium": map[string]any{
+				"default": 1073741824,
+			},
+		},
+		OnGroupRemoval: GroupAccountRemovalPolicyRemove,
+	}, cfg.Ytsaurus[0].GroupAccounts)
+	require.Equal(t, GroupPoolsConfig{
+		Enabled:          true,
+		PoolTree:         "physical",
+		ParentPoolPath:   "research/teams",
+		NameReplacements: []ReplacementPair{{From: ".", To: "-"}},
+		Attributes: map[string]any{
+			"strong_guarantee_resources": map[string]any{"cpu": 10},
+			"weight":                     1,
+		},
+		OnGroupRemoval: GroupPoolRemovalPolicyDetach,
+	}, cfg.Ytsaurus[0].GroupPools)
 
 	require.Equal(t, "WARN", cfg.Logging.Level)
 	require.Equal(t, true, cfg.Logging.IsProduction)
@@ -49,3 +129,38 @@ func TestAzureConfig(t *testing.T) {
 	require.NoError(t, err)
 	logger.Debugw("test logging message", "key", "val")
 }
+
+func TestYtsaurusClustersConfig(t *testing.T) {
+	cfg, err := unmarshallConfig([]byte(`
+ytsaurus:
+  proxy: hahn
+  apply_user_changes: true
+`))
+	require.NoError(t, err)
+	require.Equal(t, YtsaurusClustersConfig{{Proxy: "hahn", ApplyUserChanges: true}}, cfg.Ytsaurus)
+
+	cfg, err = unmarshallConfig([]byte(`
+ytsaurus:
+  - proxy: hahn
+    apply_user_changes: true
+  - name: arnold-rpc
+    proxy: arnold
+    transport: rpc
+    filters:
+      group_ids: ["fake-az-acme.hq"]
+      exclude_group_name_regexes: ["^test-"]
+`))
+	require.NoError(t, err)
+	require.Equal(t, YtsaurusClustersConfig{
+		{Proxy: "hahn", ApplyUserChanges: true},
+		{
+			Name:      "arnold-rpc",
+			Proxy:     "arnold",
+			Transport: YtsaurusTransportRPC,
+			Filters: ClusterFiltersConfig{
+				GroupIDs:                []string{"fake-az-acme.hq"},
+				ExcludeGroupNameRegexes: []string{"^test-"},
+			},
+		},
+	}, cfg.Ytsaurus)
+}
@@ -15,7 +15,12 @@ import (
 type SourceUser interface {
 	GetID() ObjectID
 	GetName() string
+	GetEmail() string
+	// GetFullName returns human-readable name of the user, which is shown in the UI.
+	GetFullName() string
 	GetRaw() (map[string]any, error)
+	// IsEnabled is false for disabled source accounts: they are banned in YTsaurus, but not removed.
+	IsEnabled() bool
 }
 
 type SourceGroup interface {
@@ -31,17 +36,73 @@ type SourceGroupWithMembers struct {
 }
 
 func (a *App) syncOnce() {
-	a.logger.Info("Start syncing")
-	defer a.logger.Info("Finish syncing")
+	a.cycleID = formatAppTime(a.clock.Now())
+	a.logger.Infow("Start syncing", "cycle_id", a.cycleID)
+	defer a.logger.Infow("Finish syncing", "cycle_id", a.cycleID)
 
-	actualYtsaurusUserMap, err := a.syncUsers()
+	a.reloadSecrets()
+
+	if a.adoptUnmanaged {
+		err := a.Adopt()
+		if err != nil {
+			a.logger.Error("adoption of unmanaged objects failed", zap.Error(err))
+			return
+		}
+	}
+
+	// Groups are fetched once: they are needed for users selection and for groups sync.
+	sourceGroups, groupsErr := a.source.GetGroupsWithMembers()
+	if groupsErr != nil {
+		groupsErr = errors.Wrap(groupsErr, "failed to get Source groups")
+		if a.syncOnlyGroupMembers {
+			a.logger.Error("user sync failed", zap.Error(groupsErr))
+			return
+		}
+	}
+
+	actualYtsaurusUserMap, managedUsernames, err := a.syncUsers(sourceGroups)
 	if err != nil {
 		a.logger.Error("user sync failed", zap.Error(err))
 		return
 	}
-	err = a.syncGroups(actualYtsaurusUserMap)
+	if groupsErr != nil {
+		a.logger.Error("group sync failed", zap.Error(groupsErr))
+		return
+	}
+	// Bound source groups only feed membership of existing unmanaged groups.
+	sourceGroups, boundSourceGroups := a.splitBoundGroups(sourceGroups)
+	groupnamesByID, unmanagedYtGroups, err := a.syncGroups(sourceGroups, actualYtsaurusUserMap, managedUsernames)
 	if err != nil {
 		a.logger.Error("group sync failed", zap.Error(err))
+		return
+	}
+	err = a.syncGroupBindings(boundSourceGroups, unmanagedYtGroups, actualYtsaurusUserMap, managedUsernames)
+	if err != nil {
+		a.logger.Error("group bindings sync failed", zap.Error(err))
+	}
+	err = a.syncGroupAccounts(sourceGroups, groupnamesByID)
+	if err != nil {
+		a.logger.Error("group accounts sync failed", zap.Error(err))
+	}
+	err = a.syncGroupPools(sourceGroups, groupnamesByID)
+	if err != nil {
+		a.logger.Error("group pools sync failed", zap.Error(err))
+	}
+	err = a.syncACLGrants(groupnamesByID)
+	if err != nil {
+		a.logger.Error("acl grants sync failed", zap.Error(err))
+	}
+}
+
+// reloadSecrets picks up rotated secrets, errors are only logged, since old clients may still work.
+func (a *App) reloadSecrets() {
+	if err := a.ytsaurus.ReloadSecretIfChanged(); err != nil {
+		a.logger.Errorw("failed to reload YTsaurus secret", zap.Error(err))
+	}
+	if reloader, ok := a.source.(secretReloader); ok {
+		if err := reloader.ReloadSecretIfChanged(); err != nil {
+			a.logger.Errorw("failed to reload source secret", zap.Error(err))
+		}
 	}
 }
 
@@ -53,34 +114,46 @@ func (a *App) isRemoveLimitReached(objectsCount int) bool {
 }
 
 // syncUsers syncs AD users with YTsaurus cluster and returns /actual/ map[ObjectID]YtsaurusUser
-// after applying changes.
-func (a *App) syncUsers() (map[ObjectID]YtsaurusUser, error) {
+// after applying changes. Source groups are used for users selection if sync_only_group_members is set.
+// Usernames of managed users are returned as well, so users are not fetched again by the next steps.
+// Old names of renamed users and names of removed ones are kept there: they can't be taken by unmanaged users
+// within the cycle and they are still actual in dry-run mode.
+func (a *App) syncUsers(sourceGroups []SourceGroupWithMembers) (map[ObjectID]YtsaurusUser, StringSet, error) {
 	a.logger.Info("Start syncing users")
 	var err error
 	var sourceUsers []SourceUser
 
 	sourceUsers, err = a.source.GetUsers()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get Source users")
+		return nil, nil, errors.Wrap(err, "failed to get Source users")
+	}
+	excludedUserIDs := NewStringSet()
+	if a.syncOnlyGroupMembers {
+		sourceUsers, excludedUserIDs = a.selectGroupMembers(sourceUsers, sourceGroups)
 	}
 
-	ytUsers, err := a.ytsaurus.GetUsers()
+	ytUsers, unmanagedYtUsers, err := a.ytsaurus.getUsersSplitByManagement()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get YTsaurus users")
+		return nil, nil, errors.Wrap(err, "failed to get YTsaurus users")
 	}
 
-	diff, err := a.diffUsers(sourceUsers, ytUsers)
+	diff, err := a.diffUsers(sourceUsers, ytUsers, unmanagedYtUsers)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to calculate users diff")
+		return nil, nil, errors.Wrap(err, "failed to calculate users diff")
 	}
 	if a.isRemoveLimitReached(len(diff.remove)) {
-		return nil, fmt.Errorf("delete limit in one cycle reached: %d %v", len(diff.remove), diff)
+		return nil, nil, fmt.Errorf("delete limit in one cycle reached: %d %v", len(diff.remove), diff)
 	}
 
-	var bannedCount, removedCount int
-	var createErrCount, updateErrCount, banOrremoveErrCount int
+	var bannedCount, removedCount, restoredMembershipsCount int
+	var createErrCount, updateErrCount, reactivateErrCount, disableErrCount, banOrremoveErrCount int
+	var homeDirectoryErrCount int
 	for _, user := range diff.remove {
-		wasBanned, wasRemoved, removeErr := a.banOrRemoveUser(user)
+		reason := BanReasonMissingFromSource
+		if a.isExcludedUser(user, excludedUserIDs) {
+			reason = BanReasonExcludedByFilter
+		}
+		wasBanned, wasRemoved, removeErr := a.banOrRemoveUser(user, reason)
 		if removeErr != nil {
 			banOrremoveErrCount++
 			a.logger.Errorw("failed to ban or remove user", zap.Error(err), "user", user)
@@ -91,12 +164,42 @@ func (a *App) syncUsers() (map[ObjectID]YtsaurusUser, error) {
 		if wasRemoved {
 			removedCount++
 		}
+		if wasRemoved && removeErr == nil {
+			err = a.ytsaurus.ArchiveHomeDirectory(user.Username, a.cycleID)
+			if err != nil {
+				homeDirectoryErrCount++
+				a.logger.Errorw("failed to archive home directory", zap.Error(err), "user", user)
+			}
+		}
 	}
 	for _, user := range diff.create {
 		err = a.ytsaurus.CreateUser(user)
 		if err != nil {
 			createErrCount++
 			a.logger.Errorw("failed to create user", zap.Error(err), "user", user)
+			continue
+		}
+		restoredMembershipsCount += a.restoreMemberships(user)
+		err = a.ytsaurus.CreateHomeDirectory(user.Username)
+		if err != nil {
+			homeDirectoryErrCount++
+			a.logger.Errorw("failed to create home directory", zap.Error(err), "user", user)
+		}
+	}
+	for _, disabledUser := range diff.disable {
+		err = a.ytsaurus.UpdateUser(disabledUser.OldUsername, disabledUser.YtsaurusUser)
+		if err != nil {
+			disableErrCount++
+			a.logger.Errorw("failed to ban disabled user", zap.Error(err), "user", disabledUser)
+			continue
+		}
+		a.onUserUpdated(disabledUser)
+	}
+	for _, reactivatedUser := range diff.reactivate {
+		err = a.reactivateUser(reactivatedUser)
+		if err != nil {
+			reactivateErrCount++
+			a.logger.Errorw("failed to reactivate user", zap.Error(err), "user", reactivatedUser)
 		}
 	}
 	for _, updatedUser := range diff.update {
@@ -104,42 +207,76 @@ func (a *App) syncUsers() (map[ObjectID]YtsaurusUser, error) {
 		if err != nil {
 			updateErrCount++
 			a.logger.Errorw("failed to update user", zap.Error(err), "user", updatedUser)
+			continue
 		}
+		a.onUserUpdated(updatedUser)
 	}
+	a.metrics.Add(metricUsersReactivated, int64(len(diff.reactivate)-reactivateErrCount))
+	a.metrics.Add(metricUserReactivateErrors, int64(reactivateErrCount))
+	a.metrics.Add(metricMembershipsRestored, int64(restoredMembershipsCount))
 	a.logger.Infow("Finish syncing users",
 		"created", len(diff.create)-createErrCount,
 		"create_errors", createErrCount,
 		"updated", len(diff.update)-updateErrCount,
 		"update_errors", updateErrCount,
+		"reactivated", len(diff.reactivate)-reactivateErrCount,
+		"reactivate_errors", reactivateErrCount,
+		"restored_memberships", restoredMembershipsCount,
+		"disabled", len(diff.disable)-disableErrCount,
+		"disable_errors", disableErrCount,
+		"skipped_disabled", diff.skippedDisabled,
+		"deferred_usernames", diff.deferredUsernames,
+		"username_collisions", diff.usernameCollisions,
+		"outside_namespace", diff.outsideNamespace,
 		"removed", removedCount,
 		"banned", bannedCount,
 		"ban_or_remove_errors", banOrremoveErrCount,
+		"home_directory_errors", homeDirectoryErrCount,
 	)
-	return diff.result, nil
+	managedUsernames := NewStringSet()
+	for _, user := range ytUsers {
+		managedUsernames.Add(user.Username)
+	}
+	for _, user := range diff.result {
+		managedUsernames.Add(user.Username)
+	}
+	return diff.result, managedUsernames, nil
 }
 
-func (a *App) syncGroups(usersMap map[ObjectID]YtsaurusUser) error {
+// syncGroups syncs source groups with YTsaurus cluster and returns /actual/ names of managed groups
+// by their source ids after applying changes. Unmanaged groups are returned as well,
+// so groups are not fetched again by the next steps.
+func (a *App) syncGroups(
+	azureGroups []SourceGroupWithMembers,
+	usersMap map[ObjectID]YtsaurusUser,
+	managedUsernames StringSet,
+) (groupnamesByID map[ObjectID]string, unmanagedYtGroups []YtsaurusGroupWithMembers, err error) {
 	a.logger.Info("Start syncing groups")
-	azureGroups, err := a.source.GetGroupsWithMembers()
+	ytGroups, unmanagedYtGroups, err := a.ytsaurus.getGroupsSplitByManagement()
 	if err != nil {
-		return errors.Wrap(err, "failed to get Source groups")
+		return nil, nil, errors.Wrap(err, "failed to get YTsaurus groups")
 	}
-	ytGroups, err := a.ytsaurus.GetGroupsWithMembers()
-	if err != nil {
-		return errors.Wrap(err, "failed to get YTsaurus groups")
+	groupnamesByID = make(map[ObjectID]string)
+	for _, group := range ytGroups {
+		if err = a.setGroupname(groupnamesByID, group.YtsaurusGroup, group.Name); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	diff, err := a.diffGroups(azureGroups, ytGroups, usersMap)
+	diff, err := a.diffGroups(azureGroups, ytGroups, usersMap, managedUsernames)
 	if err != nil {
-		return errors.Wrap(err, "failed to calculate groups diff")
+		return nil, nil, errors.Wrap(err, "failed to calculate groups diff")
 	}
 	if a.isRemoveLimitReached(len(diff.groupsToRemove)) {
-		return fmt.Errorf("delete limit in one cycle reached: %d %v", len(diff.groupsToRemove), diff)
+		return nil, nil, fmt.Errorf("delete limit in one cycle reached: %d %v", len(diff.groupsToRemove), diff)
 	}
 
 	var createErrCount, updateErrCount, removeErrCount int
 	for _, group := range diff.groupsToRemove {
 		err = a.ytsaurus.RemoveGroup(group.Name)
+		if err == nil {
+			err = a.setGroupname(groupnamesByID, group, "")
+		}
 		if err != nil {
 			removeErrCount++
 			a.logger.Errorw("failed to remove group", zap.Error(err), "group", group)
@@ -147,6 +284,9 @@ func (a *App) syncGroups(usersMap map[ObjectID]YtsaurusUser) error {
 	}
 	for _, group := range diff.groupsToCreate {
 		err = a.ytsaurus.CreateGroup(group)
+		if err == nil {
+			err = a.setGroupname(groupnamesByID, group, group.Name)
+		}
 		if err != nil {
 			createErrCount++
 			a.logger.Errorw("failed to create group", zap.Error(err), "group", group)
@@ -154,6 +294,9 @@ func (a *App) syncGroups(usersMap map[ObjectID]YtsaurusUser) error {
 	}
 	for _, updatedGroup := range diff.groupsToUpdate {
 		err = a.ytsaurus.UpdateGroup(updatedGroup.OldName, updatedGroup.YtsaurusGroup)
+		if err == nil {
+			err = a.setGroupname(groupnamesByID, updatedGroup.YtsaurusGroup, updatedGroup.Name)
+		}
 		if err != nil {
 			updateErrCount++
 			a.logger.Errorw("failed to update group", zap.Error(err), "group", updatedGroup)
@@ -166,10 +309,11 @@ func (a *App) syncGroups(usersMap map[ObjectID]YtsaurusUser) error {
 		"update_errors", updateErrCount,
 		"removed", len(diff.groupsToRemove)-removeErrCount,
 		"remove_errors", removeErrCount,
+		"outside_namespace", diff.outsideNamespace,
 	)
 
 	a.logger.Info("Start syncing group memberships")
-	var addMemberErrCount, removeMemberErrCount int
+	var addMemberErrCount, removeMemberErrCount, removeUnmanagedMemberErrCount int
 	for _, membership := range diff.membersToRemove {
 		err = a.ytsaurus.RemoveMember(membership.Username, membership.GroupName)
 		if err != nil {
@@ -178,6 +322,13 @@ func (a *App) syncGroups(usersMap map[ObjectID]YtsaurusUser) error {
 			// TODO: alerts
 		}
 	}
+	for _, membership := range diff.unmanagedMembers.remove {
+		err = a.ytsaurus.RemoveUnmanagedMember(membership.Username, membership.GroupName)
+		if err != nil {
+			removeUnmanagedMemberErrCount++
+			a.logger.Errorw("failed to remove unmanaged member", zap.Error(err), "member", membership.Username, "group", membership.GroupName)
+		}
+	}
 	for _, membership := range diff.membersToAdd {
 		err = a.ytsaurus.AddMember(membership.Username, membership.GroupName)
 		if err != nil {
@@ -192,7 +343,25 @@ func (a *App) syncGroups(usersMap map[ObjectID]YtsaurusUser) error {
 		"add_errors", addMemberErrCount,
 		"removed", len(diff.membersToRemove)-removeMemberErrCount,
 		"remove_errors", removeMemberErrCount,
+		"removed_unmanaged", len(diff.unmanagedMembers.remove)-removeUnmanagedMemberErrCount,
+		"remove_unmanaged_errors", removeUnmanagedMemberErrCount,
+		"preserved_unmanaged", diff.unmanagedMembers.preserved,
+		"reported_unmanaged", diff.unmanagedMembers.reported,
 	)
+	return groupnamesByID, unmanagedYtGroups, nil
+}
+
+// setGroupname records the name of the managed group by its source id, empty name removes the group.
+func (a *App) setGroupname(groupnamesByID map[ObjectID]string, group YtsaurusGroup, groupname string) error {
+	sourceGroup, err := a.source.CreateGroupFromRaw(group.SourceRaw)
+	if err != nil {
+		return errors.Wrap(err, "failed to create azure group from source")
+	}
+	if groupname == "" {
+		delete(groupnamesByID, sourceGroup.GetID())
+		return nil
+	}
+	groupnamesByID[sourceGroup.GetID()] = groupname
 	return nil
 }
 
@@ -202,16 +371,22 @@ type groupDiff struct {
 	groupsToUpdate  []UpdatedYtsaurusGroup
 	membersToAdd    []YtsaurusMembership
 	membersToRemove []YtsaurusMembership
+	// outsideNamespace is a number of creations and renames, which are skipped because of names outside the namespace.
+	outsideNamespace int
+	unmanagedMembers unmanagedMembersDiff
 }
 
 func (a *App) diffGroups(
 	sourceGroups []SourceGroupWithMembers,
 	ytGroups []YtsaurusGroupWithMembers,
 	usersMap map[ObjectID]YtsaurusUser,
+	managedUsernames StringSet,
 ) (*groupDiff, error) {
 	var groupsToCreate, groupsToRemove []YtsaurusGroup
 	var groupsToUpdate []UpdatedYtsaurusGroup
 	var membersToAdd, membersToRemove []YtsaurusMembership
+	outsideNamespace := 0
+	var unmanagedMembers unmanagedMembersDiff
 
 	sourceGroupsWithMembersMap := make(map[ObjectID]SourceGroupWithMembers)
 	for _, group := range sourceGroups {
@@ -234,6 +409,13 @@ func (a *App) diffGroups(
 			if err != nil {
 				return nil, errors.Wrap(err, "failed to build Ytsaurus group")
 			}
+			if !a.groupNamespace.contains(newYtsaurusGroup.Name) {
+				outsideNamespace++
+				a.logger.Errorw("Group can't be created, its name is outside of the managed namespace",
+					"groupname", newYtsaurusGroup.Name,
+				)
+				continue
+			}
 			groupsToCreate = append(groupsToCreate, newYtsaurusGroup)
 			for username := range a.buildYtsaurusGroupMembers(sourceGroupWithMembers, usersMap).Iter() {
 				membersToAdd = append(membersToAdd, YtsaurusMembership{
@@ -254,6 +436,14 @@ func (a *App) diffGroups(
 
 		// Collecting groups with changed Source fields (actually we have only displayName for now which
 		// should change, though we still handle that just in case).
+		newGroupname := a.buildGroupName(sourceGroupWithMembers.SourceGroup)
+		if newGroupname != ytGroupWithMembers.Name && !a.groupNamespace.contains(newGroupname) {
+			outsideNamespace++
+			a.logger.Errorw("Group can't be renamed, the new name is outside of the managed namespace",
+				"groupname", ytGroupWithMembers.Name,
+				"new_groupname", newGroupname,
+			)
+		}
 		groupChanged, updatedYtGroup, err := a.isGroupChanged(sourceGroupWithMembers.SourceGroup, ytGroupWithMembers.YtsaurusGroup)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to check if group is changed")
@@ -271,6 +461,7 @@ func (a *App) diffGroups(
 		}
 
 		membersCreate, membersRemove := a.isGroupMembersChanged(sourceGroupWithMembers, ytGroupWithMembers, usersMap)
+		membersRemove = a.applyUnmanagedMembersPolicy(&unmanagedMembers, actualGroupname, objectID, membersRemove, managedUsernames)
 		for _, username := range membersCreate {
 			membersToAdd = append(membersToAdd, YtsaurusMembership{
 				GroupName: actualGroupname,
@@ -291,19 +482,36 @@ func (a *App) diffGroups(
 		groupsToRemove:  groupsToRemove,
 		membersToAdd:    membersToAdd,
 		membersToRemove: membersToRemove,
+
+		outsideNamespace: outsideNamespace,
+		unmanagedMembers: unmanagedMembers,
 	}, nil
 }
 
 type usersDiff struct {
 	create []YtsaurusUser
 	update []UpdatedYtsaurusUser
-	remove []YtsaurusUser
-	result map[ObjectID]YtsaurusUser
+	// reactivate contains banned users, which have returned to the source or were enabled back.
+	reactivate []UpdatedYtsaurusUser
+	// disable contains users, which are going to be banned because their source accounts are disabled.
+	disable []UpdatedYtsaurusUser
+	remove  []YtsaurusUser
+	result  map[ObjectID]YtsaurusUser
+	// skippedDisabled is a number of disabled source users, which are not created in YTsaurus.
+	skippedDisabled int
+	// deferredUsernames is a number of creations and renames postponed until the target username is freed.
+	deferredUsernames int
+	// usernameCollisions is a number of creations and renames, which are skipped because of username collisions.
+	usernameCollisions int
+	// outsideNamespace is a number of creations and renames, which are skipped because of usernames outside the namespace.
+	outsideNamespace int
 }
 
+// diffUsers calculates changes of managed users, unmanaged ones are used only to detect username collisions.
 func (a *App) diffUsers(
 	sourceUsers []SourceUser,
 	ytUsers []YtsaurusUser,
+	unmanagedYtUsers []YtsaurusUser,
 ) (*usersDiff, error) {
 	sourceUsersMap := make(map[ObjectID]SourceUser)
 	for _, user := range sourceUsers {
@@ -322,10 +530,16 @@ func (a *App) diffUsers(
 	}
 
 	var create, remove []YtsaurusUser
-	var update []UpdatedYtsaurusUser
+	var update, reactivate, disable []UpdatedYtsaurusUser
+	skippedDisabled := 0
 
 	for objectID, sourceUser := range sourceUsersMap {
 		if _, ok := ytUsersMap[objectID]; !ok {
+			if !sourceUser.IsEnabled() {
+				a.logger.Debugw("Skipping creation of disabled user", "id", objectID, "name", sourceUser.GetName())
+				skippedDisabled++
+				continue
+			}
 			ytUser, err := a.buildYtsaurusUser(sourceUser)
 			if err != nil {
 				return nil, errors.Wrap(err, "failed to create Ytsaurus user from source user")
@@ -346,6 +560,26 @@ func (a *App) diffUsers(
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create Ytsaurus user from source user")
 		}
+		renameForbidden := false
+		if newYtUser.Username != ytUser.Username && a.userRenamePolicy == UserRenamePolicyForbid {
+			newYtUser.Username = ytUser.Username
+			renameForbidden = true
+		}
+		switch {
+		case ytUser.BanReason == BanReasonManual:
+			// Manual ban is kept even if user is present in the source.
+			newYtUser = newYtUser.WithBanOf(ytUser)
+		case !sourceUser.IsEnabled() && ytUser.BanReason == BanReasonAccountDisabled:
+			newYtUser = newYtUser.WithBanOf(ytUser)
+		case !sourceUser.IsEnabled():
+			// Disabled users are banned without removal scheduling (even if they were banned for other reason before).
+			newYtUser.BannedSince = ytUser.BannedSince
+			if !ytUser.IsBanned() {
+				newYtUser.BannedSince = a.clock.Now()
+			}
+			newYtUser.BanReason = BanReasonAccountDisabled
+			newYtUser.BanCycleID = a.cycleID
+		}
 		userChanged, updatedYtUser, err := a.isUserChanged(newYtUser, ytUser)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to check if user was changed")
@@ -353,15 +587,35 @@ func (a *App) diffUsers(
 		if !userChanged {
 			continue
 		}
-		update = append(update, updatedYtUser)
+		if renameForbidden {
+			a.logger.Warnw("User rename is forbidden by policy, the old username is kept",
+				"username", ytUser.Username,
+				"new_username", a.buildUsername(sourceUser),
+			)
+		}
+		switch {
+		case ytUser.IsBanned() && !newYtUser.IsBanned():
+			reactivate = append(reactivate, updatedYtUser)
+		case !ytUser.IsBanned() && newYtUser.IsBanned():
+			disable = append(disable, updatedYtUser)
+		default:
+			update = append(update, updatedYtUser)
+		}
 		resultUsersMap[objectID] = updatedYtUser.YtsaurusUser
 	}
-	return &usersDiff{
-		create: create,
-		update: update,
-		remove: remove,
-		result: resultUsersMap,
-	}, nil
+	diff := &usersDiff{
+		create:     create,
+		update:     update,
+		reactivate: reactivate,
+		disable:    disable,
+		remove:     remove,
+		result:     resultUsersMap,
+
+		skippedDisabled: skippedDisabled,
+	}
+	a.enforceUserNamespace(diff)
+	a.resolveUsernameConflicts(diff, ytUsers, unmanagedYtUsers)
+	return diff, nil
 }
 
 func (a *App) buildUsername(sourceUser SourceUser) string {
@@ -372,7 +626,7 @@ func (a *App) buildUsername(sourceUser SourceUser) string {
 		}
 	}
 	username = strings.ToLower(username)
-	return username
+	return a.userNamespace.apply(username)
 }
 
 func (a *App) buildGroupName(sourceGroup SourceGroup) string {
@@ -383,7 +637,7 @@ func (a *App) buildGroupName(sourceGroup SourceGroup) string {
 		}
 	}
 	name = strings.ToLower(name)
-	return name
+	return a.groupNamespace.apply(name)
 }
 
 func (a *App) buildSourceUser(ytUser *YtsaurusUser) (SourceUser, error) {
@@ -393,9 +647,9 @@ func (a *App) buildSourceUser(ytUser *YtsaurusUser) (SourceUser, error) {
 	return a.source.CreateUserFromRaw(ytUser.SourceRaw)
 }
 
-func (a *App) buildSourceGroup(ytGroup *YtsaurusGroupWithMembers) (SourceUser, error) {
+func (a *App) buildSourceGroup(ytGroup *YtsaurusGroupWithMembers) (SourceGroup, error) {
 	if ytGroup.IsManuallyManaged() {
-		return nil, errors.New("user is manually managed and can't be converted to source user")
+		return nil, errors.New("group is manually managed and can't be converted to source group")
 	}
 	return a.source.CreateGroupFromRaw(ytGroup.SourceRaw)
 }
@@ -405,12 +659,20 @@ func (a *App) buildYtsaurusUser(sourceUser SourceUser) (YtsaurusUser, error) {
 	if err != nil {
 		return YtsaurusUser{}, err
 	}
-	return YtsaurusUser{
+	user := YtsaurusUser{
 		Username:  a.buildUsername(sourceUser),
 		SourceRaw: sourceRaw,
 		// If we have Source user —> he is not banned.
 		BannedSince: time.Time{},
-	}, nil
+	}
+	// Values of not configured attributes are left empty, as they are read from YTsaurus.
+	if a.userAttributes.EmailAttributeName != "" {
+		user.Email = sourceUser.GetEmail()
+	}
+	if a.userAttributes.FullNameAttributeName != "" {
+		user.FullName = sourceUser.GetFullName()
+	}
+	return user, nil
 }
 
 func (a *App) buildYtsaurusGroup(sourceGroup SourceGroup) (YtsaurusGroup, error) {
@@ -455,12 +717,23 @@ func (a *App) isUserChanged(newYtUser YtsaurusUser, ytUser YtsaurusUser) (bool,
 	if err != nil {
 		return false, UpdatedYtsaurusUser{}, err
 	}
-	if newYtUser.Username == ytUser.Username && bytes.Equal(newSourceRaw, oldSourceRaw) && newYtUser.BannedSince == ytUser.BannedSince {
+	if newYtUser.Username == ytUser.Username &&
+		bytes.Equal(newSourceRaw, oldSourceRaw) &&
+		isSameBan(newYtUser, ytUser) &&
+		newYtUser.Email == ytUser.Email &&
+		newYtUser.FullName == ytUser.FullName {
 		return false, UpdatedYtsaurusUser{}, nil
 	}
 	return true, UpdatedYtsaurusUser{YtsaurusUser: newYtUser, OldUsername: ytUser.Username}, nil
 }
 
+func isSameBan(left, right YtsaurusUser) bool {
+	return left.BannedSince.Equal(right.BannedSince) &&
+		left.BanReason == right.BanReason &&
+		left.BanCycleID == right.BanCycleID &&
+		left.RemoveAfter.Equal(right.RemoveAfter)
+}
+
 // UpdatedYtsaurusGroup is a wrapper for YtsaurusGroup, because it is handy to store old groupname for update,
 // because groupnames can be changed.
 type UpdatedYtsaurusGroup struct {
@@ -482,7 +755,11 @@ func (a *App) isGroupChanged(sourceGroup SourceGroup, ytGroup YtsaurusGroup) (bo
 	if err != nil {
 		return false, UpdatedYtsaurusGroup{}, err
 	}
-	if bytes.Equal(newSourceRaw, oldSourceRaw) {
+	// Groups are renamed after changes of the namespace or groupname replacements, unless the new name is outside the namespace.
+	if newGroup.Name != ytGroup.Name && !a.groupNamespace.contains(newGroup.Name) {
+		newGroup.Name = ytGroup.Name
+	}
+	if bytes.Equal(newSourceRaw, oldSourceRaw) && newGroup.Name == ytGroup.Name {
 		return false, UpdatedYtsaurusGroup{}, nil
 	}
 	return true, UpdatedYtsaurusGroup{YtsaurusGroup: newGroup, OldName: ytGroup.Name}, nil
@@ -498,19 +775,129 @@ func (a *App) isGroupMembersChanged(sourceGroup SourceGroupWithMembers, ytGroup
 	return
 }
 
-func (a *App) banOrRemoveUser(user YtsaurusUser) (wasBanned, wasRemoved bool, err error) {
+// reactivateUser unbans the user, which has returned to the source, and notifies about it.
+func (a *App) reactivateUser(user UpdatedYtsaurusUser) error {
+	err := a.ytsaurus.UpdateUser(user.OldUsername, user.YtsaurusUser)
+	if err != nil {
+		return err
+	}
+	a.onUserUpdated(user)
+	sourceUser, err := a.buildSourceUser(&user.YtsaurusUser)
+	if err != nil {
+		return errors.Wrap(err, "failed to build source user")
+	}
+	// User wasn't removed, so it still has all the memberships.
+	err = a.ytsaurus.ForgetMemberships(sourceUser.GetID())
+	if err != nil {
+		a.logger.Errorw("failed to forget recorded memberships", zap.Error(err), "user", user)
+	}
+
+	a.logger.Infow("User reactivated", "username", user.Username, "old_username", user.OldUsername)
+	err = a.notifier.Notify(NotificationEvent{
+		Type:    notificationUserReactivated,
+		Subject: user.Username,
+		Time:    a.clock.Now().UTC(),
+		Details: map[string]any{
+			"old_username": user.OldUsername,
+			"source_id":    sourceUser.GetID(),
+		},
+	})
+	if err != nil {
+		a.logger.Errorw("failed to notify about user reactivation", zap.Error(err), "user", user)
+	}
+	return nil
+}
+
+// restoreMemberships restores memberships recorded at ban time for the recreated user
+// and returns the number of restored memberships.
+func (a *App) restoreMemberships(user YtsaurusUser) int {
+	sourceUser, err := a.buildSourceUser(&user)
+	if err != nil {
+		a.logger.Errorw("failed to build source user", zap.Error(err), "user", user)
+		return 0
+	}
+	restored, err := a.ytsaurus.RestoreMemberships(sourceUser.GetID(), user.Username)
+	if err != nil {
+		a.logger.Errorw("failed to restore memberships", zap.Error(err), "user", user)
+	}
+	if restored > 0 {
+		a.logger.Infow("Restored memberships for the returned user", "username", user.Username, "count", restored)
+	}
+	return restored
+}
+
+// selectGroupMembers returns source users, which are members of at least one of the groups or listed in extra users,
+// and ids of the other (excluded) source users.
+func (a *App) selectGroupMembers(sourceUsers []SourceUser, sourceGroups []SourceGroupWithMembers) ([]SourceUser, StringSet) {
+	memberIDs := NewStringSet()
+	for _, group := range sourceGroups {
+		memberIDs = memberIDs.Union(group.Members)
+	}
+
+	var selected []SourceUser
+	excludedIDs := NewStringSet()
+	for _, user := range sourceUsers {
+		if memberIDs.Contains(user.GetID()) || a.extraUsers.Contains(user.GetID()) || a.extraUsers.Contains(user.GetName()) {
+			selected = append(selected, user)
+			continue
+		}
+		a.logger.Debugw("User is not a member of synced groups", "id", user.GetID(), "name", user.GetName())
+		excludedIDs.Add(user.GetID())
+	}
+	a.logger.Infow("Selected members of synced groups", "selected", len(selected), "excluded", excludedIDs.Cardinality())
+	return selected, excludedIDs
+}
+
+// isExcludedUser checks if the YTsaurus user is missing from the selected users, but is present in the source.
+func (a *App) isExcludedUser(user YtsaurusUser, excludedUserIDs StringSet) bool {
+	if excludedUserIDs.Cardinality() == 0 {
+		return false
+	}
+	sourceUser, err := a.buildSourceUser(&user)
+	if err != nil {
+		a.logger.Errorw("failed to build source user", zap.Error(err), "user", user)
+		return false
+	}
+	return excludedUserIDs.Contains(sourceUser.GetID())
+}
+
+func (a *App) banOrRemoveUser(user YtsaurusUser, reason BanReason) (wasBanned, wasRemoved bool, err error) {
 	// Ban settings is disabled.
 	if a.banDuration == 0 {
 		return false, true, a.ytsaurus.RemoveUser(user.Username)
 	}
 	// If user is not already banned we should do it.
-	if !user.IsBanned() {
-		return true, false, a.ytsaurus.BanUser(user.Username)
+	// Disabled users are banned without scheduled removal, so they are banned again when they become missing.
+	if !user.IsBanned() || user.BanReason == BanReasonAccountDisabled {
+		sourceUser, err := a.buildSourceUser(&user)
+		if err != nil {
+			return false, false, errors.Wrap(err, "failed to build source user")
+		}
+		err = a.ytsaurus.RecordMemberships(sourceUser.GetID(), user.Username)
+		if err != nil {
+			a.logger.Errorw("failed to record memberships", zap.Error(err), "user", user)
+		}
+		removeAfter := a.clock.Now().Add(a.banDuration)
+		return true, false, a.ytsaurus.BanUser(user.Username, reason, a.cycleID, removeAfter)
 	}
 	// If user was banned longer than setting permits, we remove it.
-	if user.IsBanned() && time.Since(user.BannedSince) > a.banDuration {
+	if user.IsBanned() && a.isRemovalDue(user) {
 		return false, true, a.ytsaurus.RemoveUser(user.Username)
 	}
-	a.logger.Debugw("user is banned, but not yet removed", "user", user.Username, "since", user.BannedSince)
+	a.logger.Debugw("user is banned, but not yet removed",
+		"user", user.Username,
+		"since", user.BannedSince,
+		"reason", user.BanReason,
+		"remove_after", user.RemoveAfter,
+	)
 	return false, false, nil
 }
+
+// isRemovalDue checks if banned user should be removed: according to the recorded scheduled removal time or,
+// if it is missing (users banned before it was recorded), according to the ban duration setting.
+func (a *App) isRemovalDue(user YtsaurusUser) bool {
+	if !user.RemoveAfter.IsZero() {
+		return a.clock.Now().After(user.RemoveAfter)
+	}
+	return a.clock.Since(user.BannedSince) > a.banDuration
+}
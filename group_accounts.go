@@ -0,0 +1,24 @@
+package main
+
+// syncGroupAccounts provisions an account for every managed group, so resource limits tuned by admins are kept.
+// Accounts of removed groups are removed or orphaned according to the policy.
+func (a *App) syncGroupAccounts(sourceGroups []SourceGroupWithMembers, groupnamesByID map[ObjectID]string) error {
+	if !a.ytsaurus.IsGroupAccountsEnabled() {
+		return nil
+	}
+	return syncGroupObjects(a, &groupObjectsSyncer[YtsaurusGroupAccount]{
+		kind: "account",
+		build: func(group YtsaurusGroup) (YtsaurusGroupAccount, bool) {
+			return YtsaurusGroupAccount{Name: group.Name, SourceRaw: group.SourceRaw}, true
+		},
+		name:          func(account YtsaurusGroupAccount) string { return account.Name },
+		sourceRaw:     func(account YtsaurusGroupAccount) map[string]any { return account.SourceRaw },
+		keepRemoved:   !a.ytsaurus.IsGroupAccountRemovalEnabled(),
+		limitRemovals: true,
+
+		get:    a.ytsaurus.GetGroupAccounts,
+		create: a.ytsaurus.CreateGroupAccount,
+		update: a.ytsaurus.UpdateGroupAccount,
+		remove: a.ytsaurus.RemoveGroupAccount,
+	}, sourceGroups, groupnamesByID)
+}
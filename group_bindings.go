@@ -0,0 +1,175 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+func validateGroupBindings(bindings []GroupBindingConfig) error {
+	sourceGroupIDs := NewStringSet()
+	groupnames := NewStringSet()
+	for idx, binding := range bindings {
+		if binding.SourceGroupID == "" || binding.Group == "" {
+			return errors.Errorf("group binding #%d: source_group_id and group are required", idx)
+		}
+		if builtinSubjectNames.Contains(binding.Group) {
+			return errors.Errorf("group binding #%d: builtin group %s can't be bound", idx, binding.Group)
+		}
+		if sourceGroupIDs.Contains(binding.SourceGroupID) {
+			return errors.Errorf("group binding #%d: source group %s is already bound", idx, binding.SourceGroupID)
+		}
+		if groupnames.Contains(binding.Group) {
+			return errors.Errorf("group binding #%d: group %s is already bound", idx, binding.Group)
+		}
+		sourceGroupIDs.Add(binding.SourceGroupID)
+		groupnames.Add(binding.Group)
+	}
+	return nil
+}
+
+// groupBindingsDiff contains membership changes of bound groups, groups themselves are never changed.
+type groupBindingsDiff struct {
+	membersToAdd     []YtsaurusMembership
+	membersToRemove  []YtsaurusMembership
+	unmanagedMembers unmanagedMembersDiff
+	// missingSourceGroups is a number of bindings, which source groups are not fetched from the source.
+	// Membership of their groups is not changed.
+	missingSourceGroups int
+	// missingGroups is a number of bindings, which groups don't exist or are managed.
+	missingGroups int
+}
+
+// isBoundSourceGroup checks if the source group feeds membership of a bound group.
+func (a *App) isBoundSourceGroup(sourceGroupID ObjectID) bool {
+	for _, binding := range a.groupBindings {
+		if binding.SourceGroupID == sourceGroupID {
+			return true
+		}
+	}
+	return false
+}
+
+// isBoundGroup checks if the YTsaurus group is bound to a source group.
+func (a *App) isBoundGroup(groupname string) bool {
+	for _, binding := range a.groupBindings {
+		if binding.Group == groupname {
+			return true
+		}
+	}
+	return false
+}
+
+// splitBoundGroups separates bound source groups, which must not be synced as managed groups.
+func (a *App) splitBoundGroups(sourceGroups []SourceGroupWithMembers) (managed, bound []SourceGroupWithMembers) {
+	if len(a.groupBindings) == 0 {
+		return sourceGroups, nil
+	}
+	for _, group := range sourceGroups {
+		if a.isBoundSourceGroup(group.SourceGroup.GetID()) {
+			bound = append(bound, group)
+			continue
+		}
+		managed = append(managed, group)
+	}
+	return managed, bound
+}
+
+func (a *App) syncGroupBindings(
+	boundSourceGroups []SourceGroupWithMembers,
+	unmanagedYtGroups []YtsaurusGroupWithMembers,
+	usersMap map[ObjectID]YtsaurusUser,
+	managedUsernames StringSet,
+) error {
+	if len(a.groupBindings) == 0 {
+		return nil
+	}
+	a.logger.Info("Start syncing group bindings")
+	diff := a.diffGroupBindings(boundSourceGroups, unmanagedYtGroups, usersMap, managedUsernames)
+
+	var err error
+	var addErrCount, removeErrCount, removeUnmanagedErrCount int
+	for _, membership := range diff.membersToRemove {
+		err = a.ytsaurus.RemoveBoundGroupMember(membership.Username, membership.GroupName)
+		if err != nil {
+			removeErrCount++
+			a.logger.Errorw("failed to remove bound group member", zap.Error(err), "user", membership.Username, "group", membership.GroupName)
+		}
+	}
+	for _, membership := range diff.unmanagedMembers.remove {
+		err = a.ytsaurus.RemoveBoundGroupMember(membership.Username, membership.GroupName)
+		if err != nil {
+			removeUnmanagedErrCount++
+			a.logger.Errorw("failed to remove unmanaged bound group member", zap.Error(err), "member", membership.Username, "group", membership.GroupName)
+		}
+	}
+	for _, membership := range diff.membersToAdd {
+		err = a.ytsaurus.AddBoundGroupMember(membership.Username, membership.GroupName)
+		if err != nil {
+			addErrCount++
+			a.logger.Errorw("failed to add bound group member", zap.Error(err), "user", membership.Username, "group", membership.GroupName)
+		}
+	}
+	a.logger.Infow("Finish syncing group bindings",
+		"bindings", len(a.groupBindings),
+		"missing_source_groups", diff.missingSourceGroups,
+		"missing_groups", diff.missingGroups,
+		"added", len(diff.membersToAdd)-addErrCount,
+		"add_errors", addErrCount,
+		"removed", len(diff.membersToRemove)-removeErrCount,
+		"remove_errors", removeErrCount,
+		"removed_unmanaged", len(diff.unmanagedMembers.remove)-removeUnmanagedErrCount,
+		"remove_unmanaged_errors", removeUnmanagedErrCount,
+		"preserved_unmanaged", diff.unmanagedMembers.preserved,
+		"reported_unmanaged", diff.unmanagedMembers.reported,
+	)
+	return nil
+}
+
+func (a *App) diffGroupBindings(
+	boundSourceGroups []SourceGroupWithMembers,
+	unmanagedYtGroups []YtsaurusGroupWithMembers,
+	usersMap map[ObjectID]YtsaurusUser,
+	managedUsernames StringSet,
+) *groupBindingsDiff {
+	sourceGroupsMap := make(map[ObjectID]SourceGroupWithMembers)
+	for _, group := range boundSourceGroups {
+		sourceGroupsMap[group.SourceGroup.GetID()] = group
+	}
+	ytGroupsMap := make(map[string]YtsaurusGroupWithMembers)
+	for _, group := range unmanagedYtGroups {
+		ytGroupsMap[group.Name] = group
+	}
+
+	diff := &groupBindingsDiff{}
+	for _, binding := range a.groupBindings {
+		sourceGroup, ok := sourceGroupsMap[binding.SourceGroupID]
+		if !ok {
+			diff.missingSourceGroups++
+			a.logger.Warnw("Bound source group is not found in the source, membership is not changed", "binding", binding)
+			continue
+		}
+		ytGroup, ok := ytGroupsMap[binding.Group]
+		if !ok {
+			diff.missingGroups++
+			a.logger.Errorw("Bound group doesn't exist or is managed", "binding", binding)
+			continue
+		}
+
+		newMembers := a.buildYtsaurusGroupMembers(sourceGroup, usersMap)
+		for _, username := range newMembers.Difference(ytGroup.Members).ToSlice() {
+			diff.membersToAdd = append(diff.membersToAdd, YtsaurusMembership{
+				GroupName: binding.Group,
+				Username:  username,
+			})
+		}
+		membersRemove := ytGroup.Members.Difference(newMembers).ToSlice()
+		membersRemove = a.applyUnmanagedMembersPolicy(&diff.unmanagedMembers, binding.Group, binding.SourceGroupID, membersRemove, managedUsernames)
+		for _, username := range membersRemove {
+			diff.membersToRemove = append(diff.membersToRemove, YtsaurusMembership{
+				GroupName: binding.Group,
+				Username:  username,
+			})
+		}
+	}
+	return diff
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// groupObjectsSyncer provisions a YTsaurus object (e.g. an account or a scheduler pool) for every managed group,
+// which was actually created or renamed by groups sync, so objects never grant access to groups not owned by the app.
+// Objects are matched with source groups by the source attribute, and only their names are synced,
+// so other object attributes tuned by admins are kept.
+type groupObjectsSyncer[T any] struct {
+	// kind is used in logs, e.g. account.
+	kind string
+	// build returns the object of the group, ok is false if the object can't be built (the group is skipped).
+	build     func(group YtsaurusGroup) (object T, ok bool)
+	name      func(object T) string
+	sourceRaw func(object T) map[string]any
+	// keepRemoved is set if objects of removed groups are kept as is, otherwise they are passed to remove.
+	keepRemoved bool
+	// limitRemovals is set if remove limit is applied to objects of removed groups.
+	limitRemovals bool
+
+	get    func() ([]T, error)
+	create func(object T) error
+	update func(oldName string, object T) error
+	remove func(name string) error
+}
+
+type updatedGroupObject[T any] struct {
+	object  T
+	oldName string
+}
+
+// groupObjectsDiff is calculated separately from the groups diff, after groups are synced.
+type groupObjectsDiff[T any] struct {
+	create []T
+	update []updatedGroupObject[T]
+	remove []T
+	// kept is a number of objects of removed groups, which are kept according to the policy.
+	kept int
+	// invalid is a number of groups, which objects can't be built.
+	invalid int
+}
+
+func syncGroupObjects[T any](
+	a *App,
+	s *groupObjectsSyncer[T],
+	sourceGroups []SourceGroupWithMembers,
+	groupnamesByID map[ObjectID]string,
+) error {
+	a.logger.Infof("Start syncing group %ss", s.kind)
+	ytObjects, err := s.get()
+	if err != nil {
+		return errors.Wrapf(err, "failed to get YTsaurus group %ss", s.kind)
+	}
+
+	diff, err := diffGroupObjects(a, s, sourceGroups, groupnamesByID, ytObjects)
+	if err != nil {
+		return errors.Wrapf(err, "failed to calculate group %ss diff", s.kind)
+	}
+	if s.limitRemovals && a.isRemoveLimitReached(len(diff.remove)) {
+		return fmt.Errorf("delete limit in one cycle reached: %d %v", len(diff.remove), diff)
+	}
+
+	var createErrCount, updateErrCount, removeErrCount int
+	for _, object := range diff.remove {
+		err = s.remove(s.name(object))
+		if err != nil {
+			removeErrCount++
+			a.logger.Errorw("failed to remove group "+s.kind, zap.Error(err), s.kind, object)
+		}
+	}
+	for _, object := range diff.create {
+		err = s.create(object)
+		if err != nil {
+			createErrCount++
+			a.logger.Errorw("failed to create group "+s.kind, zap.Error(err), s.kind, object)
+		}
+	}
+	for _, updated := range diff.update {
+		err = s.update(updated.oldName, updated.object)
+		if err != nil {
+			updateErrCount++
+			a.logger.Errorw("failed to update group "+s.kind, zap.Error(err), s.kind, updated.object, "old_name", updated.oldName)
+		}
+	}
+	a.logger.Infow(fmt.Sprintf("Finish syncing group %ss", s.kind),
+		"created", len(diff.create)-createErrCount,
+		"create_errors", createErrCount,
+		"updated", len(diff.update)-updateErrCount,
+		"update_errors", updateErrCount,
+		"removed", len(diff.remove)-removeErrCount,
+		"remove_errors", removeErrCount,
+		"kept", diff.kept,
+		"invalid", diff.invalid,
+	)
+	return nil
+}
+
+func diffGroupObjects[T any](
+	a *App,
+	s *groupObjectsSyncer[T],
+	sourceGroups []SourceGroupWithMembers,
+	groupnamesByID map[ObjectID]string,
+	ytObjects []T,
+) (*groupObjectsDiff[T], error) {
+	ytObjectsMap := make(map[ObjectID]T)
+	for _, object := range ytObjects {
+		sourceGroup, err := a.source.CreateGroupFromRaw(s.sourceRaw(object))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create source group from %s %s", s.kind, s.name(object))
+		}
+		ytObjectsMap[sourceGroup.GetID()] = object
+	}
+
+	diff := &groupObjectsDiff[T]{}
+	sourceGroupIDs := NewStringSet()
+	for _, sourceGroupWithMembers := range sourceGroups {
+		sourceGroup := sourceGroupWithMembers.SourceGroup
+		sourceGroupIDs.Add(sourceGroup.GetID())
+		// Group isn't managed: its creation failed or its name is outside the namespace.
+		groupname, ok := groupnamesByID[sourceGroup.GetID()]
+		if !ok {
+			continue
+		}
+		sourceRaw, err := sourceGroup.GetRaw()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get source group raw")
+		}
+		object, ok := s.build(YtsaurusGroup{Name: groupname, SourceRaw: sourceRaw})
+		if !ok {
+			diff.invalid++
+			continue
+		}
+
+		ytObject, ok := ytObjectsMap[sourceGroup.GetID()]
+		if !ok {
+			diff.create = append(diff.create, object)
+			continue
+		}
+		if s.name(ytObject) != s.name(object) {
+			diff.update = append(diff.update, updatedGroupObject[T]{
+				object:  object,
+				oldName: s.name(ytObject),
+			})
+		}
+	}
+
+	for objectID, ytObject := range ytObjectsMap {
+		if sourceGroupIDs.Contains(objectID) {
+			continue
+		}
+		if s.keepRemoved {
+			diff.kept++
+			continue
+		}
+		diff.remove = append(diff.remove, ytObject)
+	}
+	return diff, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+// syncGroupPools provisions a scheduler pool for every managed group, pool names are built from actual names
+// of managed groups with pool name replacements, so `use` is never granted to groups not owned by the app.
+// Pools of removed groups are removed or detached according to the policy,
+// so remove limit is applied only to real removals.
+func (a *App) syncGroupPools(sourceGroups []SourceGroupWithMembers, groupnamesByID map[ObjectID]string) error {
+	if !a.ytsaurus.IsGroupPoolsEnabled() {
+		return nil
+	}
+	return syncGroupObjects(a, &groupObjectsSyncer[YtsaurusGroupPool]{
+		kind: "pool",
+		build: func(group YtsaurusGroup) (YtsaurusGroupPool, bool) {
+			pool := YtsaurusGroupPool{
+				Name:      a.ytsaurus.GroupPoolName(group.Name),
+				Groupname: group.Name,
+				SourceRaw: group.SourceRaw,
+			}
+			if pool.Name == "" {
+				a.logger.Errorw("Group name can't be converted to pool name, check pool name replacements",
+					"groupname", group.Name,
+				)
+				return pool, false
+			}
+			return pool, true
+		},
+		name:          func(pool YtsaurusGroupPool) string { return pool.Name },
+		sourceRaw:     func(pool YtsaurusGroupPool) map[string]any { return pool.SourceRaw },
+		limitRemovals: a.ytsaurus.IsGroupPoolRemovalEnabled(),
+
+		get:    a.ytsaurus.GetGroupPools,
+		create: a.ytsaurus.CreateGroupPool,
+		update: a.ytsaurus.UpdateGroupPool,
+		remove: a.ytsaurus.RemoveGroupPool,
+	}, sourceGroups, groupnamesByID)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// groupSelector selects source groups by ids and names, it is shared by the source group selection
+// and cluster filters. Groups are selected if they match any of include rules (all groups are selected
+// if there are none), then exclude regexes are applied.
+type groupSelector struct {
+	ids            StringSet
+	namePrefixes   []string
+	includeRegexes []*regexp.Regexp
+	excludeRegexes []*regexp.Regexp
+}
+
+func newGroupSelector(ids, namePrefixes, includeNameRegexes, excludeNameRegexes []string) (*groupSelector, error) {
+	includeRegexes, err := compileRegexes(includeNameRegexes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid include name regex")
+	}
+	excludeRegexes, err := compileRegexes(excludeNameRegexes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid exclude name regex")
+	}
+	return &groupSelector{
+		ids:            NewStringSetFromItems(ids...),
+		namePrefixes:   namePrefixes,
+		includeRegexes: includeRegexes,
+		excludeRegexes: excludeRegexes,
+	}, nil
+}
+
+func compileRegexes(expressions []string) ([]*regexp.Regexp, error) {
+	var regexes []*regexp.Regexp
+	for _, expression := range expressions {
+		regex, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compile %q", expression)
+		}
+		regexes = append(regexes, regex)
+	}
+	return regexes, nil
+}
+
+// isSelected checks the group against the rules. includedIDs are ids of groups included by rules,
+// which are resolved by the caller, it is nil if there are no such rules.
+func (s *groupSelector) isSelected(id, name string, includedIDs StringSet) bool {
+	hasIncludeRules := s.ids.Cardinality() > 0 ||
+		len(s.namePrefixes) > 0 ||
+		len(s.includeRegexes) > 0 ||
+		includedIDs != nil
+	if hasIncludeRules && !s.isIncluded(id, name, includedIDs) {
+		return false
+	}
+	for _, regex := range s.excludeRegexes {
+		if regex.MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *groupSelector) isIncluded(id, name string, includedIDs StringSet) bool {
+	if s.ids.Contains(id) || includedIDs != nil && includedIDs.Contains(id) {
+		return true
+	}
+	for _, prefix := range s.namePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	for _, regex := range s.includeRegexes {
+		if regex.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -6,25 +6,33 @@ import (
 	"github.com/jessevdk/go-flags"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"k8s.io/utils/clock"
 )
 
-var options struct {
-	ConfigFile string `long:"config" description:"Config file path" required:"true"`
+type cliOptions struct {
+	ConfigFile             string   `long:"config" description:"Config file path" required:"true"`
+	Adopt                  bool     `long:"adopt" description:"Adopt unmanaged YTsaurus users and groups matching source objects and exit"`
+	MigrateSourceAttribute bool     `long:"migrate-source-attribute" description:"Migrate source attribute from ytsaurus.source_attribute_migration.legacy_attribute_name and exit"`
+	Ban                    []string `long:"ban" description:"Ban managed YTsaurus user manually and exit (can be repeated)"`
+	Unban                  []string `long:"unban" description:"Unban managed YTsaurus user and exit (can be repeated)"`
 }
 
+var options cliOptions
+
 func main() {
 	_, err := flags.Parse(&options)
 	if err != nil {
 		panic("failed to parse options: " + err.Error())
 	}
 
-	err = run(options.ConfigFile)
+	err = run(options)
 	if err != nil {
 		panic("failed to start the application: " + err.Error())
 	}
 }
 
-func run(configFilePath string) error {
+func run(opts cliOptions) error {
+	configFilePath := opts.ConfigFile
 	fmt.Println("Config file path:", configFilePath)
 	content, err := readConfig(configFilePath)
 	if err != nil {
@@ -51,11 +59,39 @@ func run(configFilePath string) error {
 		"struct", cfg,
 	)
 
-	app, err := NewApp(cfg, logger)
+	if opts.MigrateSourceAttribute {
+		for i := range cfg.Ytsaurus {
+			clusterCfg := &cfg.Ytsaurus[i]
+			yt, err := NewYtsaurus(clusterCfg, logger.With("cluster", clusterName(clusterCfg)), clock.RealClock{})
+			if err != nil {
+				return err
+			}
+			err = yt.MigrateSourceAttribute(clusterCfg.SourceAttributeMigration)
+			if err != nil {
+				return errors.Wrapf(err, "cluster %s", clusterName(clusterCfg))
+			}
+		}
+		return nil
+	}
+
+	app, err := NewClusters(cfg, logger)
 	if err != nil {
 		return err
 	}
 
+	if opts.Adopt {
+		return app.Adopt()
+	}
+	if len(opts.Ban) > 0 || len(opts.Unban) > 0 {
+		if err = app.UnbanUsers(opts.Unban); err != nil {
+			return err
+		}
+		return app.BanUsers(opts.Ban)
+	}
+
+	if cfg.App.MetricsAddress != "" {
+		serveMetrics(cfg.App.MetricsAddress, logger)
+	}
 	defer app.Stop()
 	app.Start()
 
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+const (
+	metricUsersReactivated     = "users_reactivated"
+	metricUserReactivateErrors = "user_reactivate_errors"
+	metricMembershipsRestored  = "memberships_restored"
+)
+
+// clusterMetrics are sync counters of clusters, keys are cluster names, values are counters of the cluster app.
+// They are published by expvar at /debug/vars, which is served on app.metrics_address.
+var clusterMetrics = expvar.NewMap("clusters")
+
+func registerClusterMetrics(name string, metrics *expvar.Map) {
+	clusterMetrics.Set(name, metrics)
+}
+
+// serveMetrics serves expvar metrics in background, errors are reported to the log.
+func serveMetrics(address string, logger appLoggerType) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	go func() {
+		err := http.ListenAndServe(address, mux)
+		logger.Errorw("metrics server stopped", zap.Error(err), "address", address)
+	}()
+}
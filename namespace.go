@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+)
+
+// subjectNamespace is a prefix and a suffix of synced user or group names.
+// It keeps synced subjects apart from builtin and manually created ones in the flat YTsaurus namespace.
+type subjectNamespace struct {
+	prefix string
+	suffix string
+}
+
+func (n subjectNamespace) apply(name string) string {
+	return n.prefix + name + n.suffix
+}
+
+// contains checks if the name can belong to a synced subject: it has the prefix and the suffix
+// and is not a name of a builtin subject.
+func (n subjectNamespace) contains(name string) bool {
+	return len(name) > len(n.prefix)+len(n.suffix) &&
+		strings.HasPrefix(name, n.prefix) &&
+		strings.HasSuffix(name, n.suffix) &&
+		!builtinSubjectNames.Contains(name)
+}
+
+// enforceUserNamespace skips creations and renames of users with usernames outside the namespace.
+// Users are not renamed in this case, other source fields are still updated.
+func (a *App) enforceUserNamespace(diff *usersDiff) {
+	var create []YtsaurusUser
+	for _, user := range diff.create {
+		if a.userNamespace.contains(user.Username) {
+			create = append(create, user)
+			continue
+		}
+		sourceUser, err := a.buildSourceUser(&user)
+		if err == nil {
+			delete(diff.result, sourceUser.GetID())
+		}
+		diff.outsideNamespace++
+		a.logger.Errorw("User can't be created, the username is outside of the managed namespace", "username", user.Username)
+	}
+	diff.create = create
+
+	for _, list := range [][]UpdatedYtsaurusUser{diff.update, diff.reactivate, diff.disable} {
+		for i := range list {
+			rename := &list[i]
+			if rename.Username == rename.OldUsername || a.userNamespace.contains(rename.Username) {
+				continue
+			}
+			newUsername := rename.Username
+			rename.Username = rename.OldUsername
+			sourceUser, err := a.buildSourceUser(&rename.YtsaurusUser)
+			if err == nil {
+				diff.result[sourceUser.GetID()] = rename.YtsaurusUser
+			}
+			diff.outsideNamespace++
+			a.logger.Errorw("User can't be renamed, the new username is outside of the managed namespace",
+				"username", rename.OldUsername,
+				"new_username", newUsername,
+			)
+		}
+	}
+}
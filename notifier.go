@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultNotificationsTimeout = 3 * time.Second
+
+	notificationUserReactivated = "user_reactivated"
+	notificationUserRenamed     = "user_renamed"
+)
+
+// NotificationEvent is a notable sync event, operators may want to know about.
+type NotificationEvent struct {
+	Type string `json:"type"`
+	// Subject is a YTsaurus user or group name the event is about.
+	Subject string         `json:"subject"`
+	Time    time.Time      `json:"time"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+type Notifier interface {
+	Notify(event NotificationEvent) error
+}
+
+func NewNotifier(cfg *NotificationsConfig, logger appLoggerType) Notifier {
+	if cfg.WebhookURL == "" {
+		return &logNotifier{logger: logger}
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultNotificationsTimeout
+	}
+	return &webhookNotifier{
+		url:     cfg.WebhookURL,
+		client:  &http.Client{Timeout: timeout},
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// logNotifier only writes events to the application log.
+type logNotifier struct {
+	logger appLoggerType
+}
+
+func (n *logNotifier) Notify(event NotificationEvent) error {
+	n.logger.Infow("Notification", "event", event)
+	return nil
+}
+
+// webhookNotifier sends events as JSON in POST requests to the configured URL.
+type webhookNotifier struct {
+	url     string
+	client  *http.Client
+	timeout time.Duration
+	logger  appLoggerType
+}
+
+func (n *webhookNotifier) Notify(event NotificationEvent) error {
+	n.logger.Infow("Notification", "event", event)
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal notification")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to build notification request")
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := n.client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "failed to send notification")
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return errors.Errorf("notification webhook responded with %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// secretReloader is implemented by clients which can pick up rotated secrets without restart.
+type secretReloader interface {
+	// ReloadSecretIfChanged re-reads the secret and rebuilds the client if the secret has changed.
+	ReloadSecretIfChanged() error
+}
+
+// secretReader reads a secret from the file if it is specified or from the env variable otherwise.
+// The file is re-read on every call, so secrets mounted from Kubernetes secrets can be rotated.
+type secretReader struct {
+	envVar string
+	file   string
+
+	last string
+}
+
+func newSecretReader(envVar, file string) *secretReader {
+	return &secretReader{envVar: envVar, file: file}
+}
+
+func (s *secretReader) read() (string, error) {
+	var secret string
+	if s.file != "" {
+		content, err := os.ReadFile(s.file)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read secret file %s", s.file)
+		}
+		// Files are often created with trailing newline.
+		secret = strings.TrimSpace(string(content))
+		if secret == "" {
+			return "", errors.Errorf("secret in %s file shouldn't be empty", s.file)
+		}
+	} else {
+		secret = os.Getenv(s.envVar)
+		if secret == "" {
+			return "", errors.Errorf("secret in %s env var shouldn't be empty", s.envVar)
+		}
+	}
+	s.last = secret
+	return secret, nil
+}
+
+// readIfChanged returns the secret and true if it differs from the previously read one.
+// Secrets from env vars never change.
+func (s *secretReader) readIfChanged() (string, bool, error) {
+	if s.file == "" {
+		return s.last, false, nil
+	}
+	previous := s.last
+	secret, err := s.read()
+	if err != nil {
+		return "", false, err
+	}
+	return secret, secret != previous, nil
+}
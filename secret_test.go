@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/clock"
+)
+
+func TestSecretReaderFileRotation(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("first\n"), 0o600))
+
+	reader := newSecretReader("TEST_SECRET_READER_UNUSED", secretFile)
+	secret, err := reader.read()
+	require.NoError(t, err)
+	require.Equal(t, "first", secret)
+
+	_, changed, err := reader.readIfChanged()
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	require.NoError(t, os.WriteFile(secretFile, []byte("second"), 0o600))
+	secret, changed, err = reader.readIfChanged()
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "second", secret)
+
+	require.NoError(t, os.WriteFile(secretFile, []byte("  \n"), 0o600))
+	_, _, err = reader.readIfChanged()
+	require.Error(t, err)
+}
+
+func TestSecretReaderEnvVar(t *testing.T) {
+	envVar := "TEST_SECRET_READER_ENV"
+	t.Setenv(envVar, "from-env")
+
+	reader := newSecretReader(envVar, "")
+	secret, err := reader.read()
+	require.NoError(t, err)
+	require.Equal(t, "from-env", secret)
+
+	t.Setenv(envVar, "rotated")
+	_, changed, err := reader.readIfChanged()
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	_, err = newSecretReader("TEST_SECRET_READER_MISSING", "").read()
+	require.Error(t, err)
+}
+
+func TestYtsaurusReloadSecretIfChanged(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(secretFile, []byte("token-1"), 0o600))
+
+	yt, err := NewYtsaurus(
+		&YtsaurusConfig{Proxy: "localhost:1", SecretFile: secretFile},
+		getDevelopmentLogger(),
+		clock.RealClock{},
+	)
+	require.NoError(t, err)
+	initialClient := yt.client
+
+	require.NoError(t, yt.ReloadSecretIfChanged())
+	require.Same(t, initialClient, yt.client)
+
+	require.NoError(t, os.WriteFile(secretFile, []byte("token-2"), 0o600))
+	require.NoError(t, yt.ReloadSecretIfChanged())
+	require.NotSame(t, initialClient, yt.client)
+}
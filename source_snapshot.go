@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+)
+
+// sourceSnapshot fetches users and groups once per sync cycle and shares them between clusters.
+// Fetch errors are recorded too, so every cluster handles them the same way as a direct source fetch.
+type sourceSnapshot struct {
+	source Source
+
+	users     []SourceUser
+	usersErr  error
+	groups    []SourceGroupWithMembers
+	groupsErr error
+}
+
+func newSourceSnapshot(source Source) *sourceSnapshot {
+	return &sourceSnapshot{source: source}
+}
+
+// refresh fetches users and groups from the source.
+func (s *sourceSnapshot) refresh() {
+	s.users, s.usersErr = s.source.GetUsers()
+	s.groups, s.groupsErr = s.source.GetGroupsWithMembers()
+}
+
+// clusterSource is a view of the source snapshot for a cluster, it applies cluster filters to groups.
+type clusterSource struct {
+	snapshot *sourceSnapshot
+	selector *groupSelector
+}
+
+func newClusterSource(snapshot *sourceSnapshot, cfg *ClusterFiltersConfig) (*clusterSource, error) {
+	selector, err := newGroupSelector(cfg.GroupIDs, nil, cfg.IncludeGroupNameRegexes, cfg.ExcludeGroupNameRegexes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid cluster filters")
+	}
+	return &clusterSource{
+		snapshot: snapshot,
+		selector: selector,
+	}, nil
+}
+
+func (s *clusterSource) GetUsers() ([]SourceUser, error) {
+	return s.snapshot.users, s.snapshot.usersErr
+}
+
+func (s *clusterSource) GetGroupsWithMembers() ([]SourceGroupWithMembers, error) {
+	if s.snapshot.groupsErr != nil {
+		return nil, s.snapshot.groupsErr
+	}
+	var groups []SourceGroupWithMembers
+	for _, group := range s.snapshot.groups {
+		if s.selector.isSelected(group.SourceGroup.GetID(), group.SourceGroup.GetName(), nil) {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+func (s *clusterSource) CreateUserFromRaw(raw map[string]any) (SourceUser, error) {
+	return s.snapshot.source.CreateUserFromRaw(raw)
+}
+
+func (s *clusterSource) CreateGroupFromRaw(raw map[string]any) (SourceGroup, error) {
+	return s.snapshot.source.CreateGroupFromRaw(raw)
+}
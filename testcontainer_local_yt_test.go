@@ -5,12 +5,14 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
 
 	"go.ytsaurus.tech/yt/go/ypath"
 	"go.ytsaurus.tech/yt/go/yt"
 )
 
 func TestLocalYtsaurus(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
 	ytLocal := NewYtsaurusLocal()
 	defer func() { require.NoError(t, ytLocal.Stop()) }()
 	require.NoError(t, ytLocal.Start())
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+)
+
+// UnmanagedMembersPolicy defines what happens with members of a synced group, which are not managed by the app
+// (manually created users or nested groups). Such members are never derived from the source.
+type UnmanagedMembersPolicy string
+
+const (
+	// UnmanagedMembersPolicyPreserve keeps unmanaged members in the group (default).
+	UnmanagedMembersPolicyPreserve UnmanagedMembersPolicy = "preserve"
+	// UnmanagedMembersPolicyRemove removes unmanaged members from the group.
+	UnmanagedMembersPolicyRemove UnmanagedMembersPolicy = "remove"
+	// UnmanagedMembersPolicyReport keeps unmanaged members in the group and logs each of them on every sync.
+	UnmanagedMembersPolicyReport UnmanagedMembersPolicy = "report"
+)
+
+func validateUnmanagedMembersPolicy(policy UnmanagedMembersPolicy) error {
+	switch policy {
+	case "", UnmanagedMembersPolicyPreserve, UnmanagedMembersPolicyRemove, UnmanagedMembersPolicyReport:
+		return nil
+	}
+	return errors.Errorf("unknown unmanaged members policy %q", policy)
+}
+
+func validateUnmanagedMembersConfig(cfg *UnmanagedMembersConfig) error {
+	if err := validateUnmanagedMembersPolicy(cfg.Policy); err != nil {
+		return err
+	}
+	for group, policy := range cfg.Groups {
+		if err := validateUnmanagedMembersPolicy(policy); err != nil {
+			return errors.Wrapf(err, "group %s", group)
+		}
+	}
+	return nil
+}
+
+// unmanagedMembersDiff is a part of the groups diff, which handles unmanaged members of synced groups.
+type unmanagedMembersDiff struct {
+	remove    []YtsaurusMembership
+	preserved int
+	reported  int
+}
+
+// unmanagedMembersPolicy returns the policy of the group, overrides are looked up by group name first.
+func (a *App) unmanagedMembersPolicy(groupname string, sourceGroupID ObjectID) UnmanagedMembersPolicy {
+	policy, ok := a.unmanagedMembers.Groups[groupname]
+	if !ok {
+		policy, ok = a.unmanagedMembers.Groups[sourceGroupID]
+	}
+	if !ok {
+		policy = a.unmanagedMembers.Policy
+	}
+	if policy == "" {
+		return UnmanagedMembersPolicyPreserve
+	}
+	return policy
+}
+
+// applyUnmanagedMembersPolicy returns managed users of members to remove from the group,
+// unmanaged members are recorded in the diff according to the group policy.
+func (a *App) applyUnmanagedMembersPolicy(
+	diff *unmanagedMembersDiff,
+	groupname string,
+	sourceGroupID ObjectID,
+	membersRemove []string,
+	managedUsernames StringSet,
+) []string {
+	var managedMembersRemove []string
+	policy := a.unmanagedMembersPolicy(groupname, sourceGroupID)
+	for _, member := range membersRemove {
+		if managedUsernames.Contains(member) {
+			managedMembersRemove = append(managedMembersRemove, member)
+			continue
+		}
+		switch policy {
+		case UnmanagedMembersPolicyRemove:
+			diff.remove = append(diff.remove, YtsaurusMembership{
+				GroupName: groupname,
+				Username:  member,
+			})
+		case UnmanagedMembersPolicyReport:
+			diff.reported++
+			a.logger.Warnw("Group has unmanaged member", "group", groupname, "member", member)
+		default:
+			diff.preserved++
+		}
+	}
+	return managedMembersRemove
+}
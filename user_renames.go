@@ -0,0 +1,193 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// UserRenamePolicy defines what happens when YTsaurus username computed from the source changes
+// (e.g. userPrincipalName is changed in Azure). Rename breaks ACLs and tokens referencing the old name.
+type UserRenamePolicy string
+
+const (
+	// UserRenamePolicyAllow renames YTsaurus user (default).
+	UserRenamePolicyAllow UserRenamePolicy = "allow"
+	// UserRenamePolicyForbid keeps the old username, other source fields are still updated.
+	UserRenamePolicyForbid UserRenamePolicy = "forbid"
+	// UserRenamePolicyNotify renames YTsaurus user and sends notification, so references can be fixed.
+	UserRenamePolicyNotify UserRenamePolicy = "notify"
+)
+
+func validateUserRenamePolicy(policy UserRenamePolicy) error {
+	switch policy {
+	case "", UserRenamePolicyAllow, UserRenamePolicyForbid, UserRenamePolicyNotify:
+		return nil
+	}
+	return errors.Errorf("unknown user rename policy %q", policy)
+}
+
+// resolveUsernameConflicts postpones creations and renames, which can't be applied in this cycle:
+//   - the target username is a target of another creation or rename (collision);
+//   - the target username belongs to another YTsaurus user (collision),
+//     unless that user is managed and renamed in this cycle too (rename chain);
+//   - the target username belongs to an unmanaged (system or manually created) user (collision);
+//   - the target username belongs to a user missing from the source, which is banned until removal.
+//
+// Users in rename chains and users waiting for removal of banned users are handled in the next cycles,
+// after their target names are freed.
+// Renames forming a cycle (e.g. a swap of two names) and collisions are reported until resolved in the source.
+func (a *App) resolveUsernameConflicts(diff *usersDiff, ytUsers, unmanagedYtUsers []YtsaurusUser) {
+	occupied := NewStringSet()
+	for _, user := range ytUsers {
+		occupied.Add(user.Username)
+	}
+	for _, user := range unmanagedYtUsers {
+		occupied.Add(user.Username)
+	}
+
+	// awaitingRemoval are usernames of users missing from the source, they are freed after the ban.
+	awaitingRemoval := NewStringSet()
+	for _, user := range diff.remove {
+		awaitingRemoval.Add(user.Username)
+	}
+
+	var renames []*UpdatedYtsaurusUser
+	for _, list := range [][]UpdatedYtsaurusUser{diff.update, diff.reactivate, diff.disable} {
+		for i := range list {
+			if list[i].Username != list[i].OldUsername {
+				renames = append(renames, &list[i])
+			}
+		}
+	}
+	// renamedAway maps old usernames to the new ones.
+	renamedAway := make(map[string]string)
+	targetsCount := make(map[string]int)
+	for _, rename := range renames {
+		renamedAway[rename.OldUsername] = rename.Username
+		targetsCount[rename.Username]++
+	}
+	for _, user := range diff.create {
+		targetsCount[user.Username]++
+	}
+
+	// isAvailable checks the target username, chained is true if it is going to be freed by another rename.
+	isAvailable := func(username string) (available, chained bool) {
+		if targetsCount[username] > 1 {
+			return false, false
+		}
+		if !occupied.Contains(username) {
+			return true, false
+		}
+		_, chained = renamedAway[username]
+		return false, chained
+	}
+
+	isAwaitingRemoval := func(username string) bool {
+		return targetsCount[username] == 1 && awaitingRemoval.Contains(username)
+	}
+
+	var create []YtsaurusUser
+	for _, user := range diff.create {
+		available, chained := isAvailable(user.Username)
+		if available {
+			create = append(create, user)
+			continue
+		}
+		sourceUser, err := a.buildSourceUser(&user)
+		if err == nil {
+			delete(diff.result, sourceUser.GetID())
+		}
+		if chained {
+			diff.deferredUsernames++
+			a.logger.Infow("User creation is postponed until the username is freed by rename", "username", user.Username)
+			continue
+		}
+		if isAwaitingRemoval(user.Username) {
+			diff.deferredUsernames++
+			a.logger.Infow("User creation is postponed until the banned user with the username is removed",
+				"username", user.Username,
+			)
+			continue
+		}
+		diff.usernameCollisions++
+		a.logger.Errorw("User can't be created, the username collides with another user", "username", user.Username)
+	}
+	diff.create = create
+
+	for _, rename := range renames {
+		available, chained := isAvailable(rename.Username)
+		if available {
+			continue
+		}
+		newUsername := rename.Username
+		rename.Username = rename.OldUsername
+		sourceUser, err := a.buildSourceUser(&rename.YtsaurusUser)
+		if err == nil {
+			diff.result[sourceUser.GetID()] = rename.YtsaurusUser
+		}
+		switch {
+		case chained && !isRenameCycle(renamedAway, rename.OldUsername, newUsername):
+			diff.deferredUsernames++
+			a.logger.Infow("User rename is postponed until the username is freed by another rename",
+				"username", rename.OldUsername,
+				"new_username", newUsername,
+			)
+		case isAwaitingRemoval(newUsername):
+			diff.deferredUsernames++
+			a.logger.Infow("User rename is postponed until the banned user with the username is removed",
+				"username", rename.OldUsername,
+				"new_username", newUsername,
+			)
+		case chained:
+			diff.usernameCollisions++
+			a.logger.Errorw("User can't be renamed, renames form a cycle",
+				"username", rename.OldUsername,
+				"new_username", newUsername,
+			)
+		default:
+			diff.usernameCollisions++
+			a.logger.Errorw("User can't be renamed, the username collides with another user",
+				"username", rename.OldUsername,
+				"new_username", newUsername,
+			)
+		}
+	}
+}
+
+// isRenameCycle follows renames starting from the target and checks if they return to the start.
+func isRenameCycle(renamedAway map[string]string, start, target string) bool {
+	current := target
+	for i := 0; i < len(renamedAway); i++ {
+		next, ok := renamedAway[current]
+		if !ok {
+			return false
+		}
+		if next == start {
+			return true
+		}
+		current = next
+	}
+	return false
+}
+
+// onUserUpdated logs applied renames and notifies about them according to the policy.
+func (a *App) onUserUpdated(user UpdatedYtsaurusUser) {
+	if user.Username == user.OldUsername {
+		return
+	}
+	a.logger.Infow("User renamed", "username", user.Username, "old_username", user.OldUsername)
+	if a.userRenamePolicy != UserRenamePolicyNotify {
+		return
+	}
+	err := a.notifier.Notify(NotificationEvent{
+		Type:    notificationUserRenamed,
+		Subject: user.Username,
+		Time:    a.clock.Now().UTC(),
+		Details: map[string]any{
+			"old_username": user.OldUsername,
+		},
+	})
+	if err != nil {
+		a.logger.Errorw("failed to notify about user rename", zap.Error(err), "user", user)
+	}
+}
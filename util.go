@@ -17,6 +17,14 @@ const (
 
 type appLoggerType = *zap.SugaredLogger
 
+// formatAppTime formats time in appTimeFormat, zero time is formatted as empty string.
+func formatAppTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(appTimeFormat)
+}
+
 func readConfig(filename string) ([]byte, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -12,6 +12,17 @@ import (
 	"go.ytsaurus.tech/yt/go/ypath"
 	"go.ytsaurus.tech/yt/go/yt"
 	"go.ytsaurus.tech/yt/go/yt/ythttp"
+	"go.ytsaurus.tech/yt/go/yt/ytrpc"
+)
+
+// YtsaurusTransport defines which proxies are used by the YTsaurus client.
+type YtsaurusTransport string
+
+const (
+	// YtsaurusTransportHTTP uses HTTP proxies (default).
+	YtsaurusTransportHTTP YtsaurusTransport = "http"
+	// YtsaurusTransportRPC uses RPC proxies, it has lower overhead for large numbers of small requests.
+	YtsaurusTransportRPC YtsaurusTransport = "rpc"
 )
 
 const (
@@ -20,8 +31,30 @@ const (
 	defaultSourceAttributeName  = "source"
 )
 
+// ytsaurusClient is a subset of yt.Client methods used by the app.
+// It is implemented by the real YTsaurus clients and by the in-memory YtsaurusFake for tests.
+type ytsaurusClient interface {
+	CreateObject(ctx context.Context, typ yt.NodeType, options *yt.CreateObjectOptions) (yt.NodeID, error)
+	CreateNode(ctx context.Context, path ypath.YPath, typ yt.NodeType, options *yt.CreateNodeOptions) (yt.NodeID, error)
+	MoveNode(ctx context.Context, src ypath.YPath, dst ypath.YPath, options *yt.MoveNodeOptions) (yt.NodeID, error)
+	NodeExists(ctx context.Context, path ypath.YPath, options *yt.NodeExistsOptions) (bool, error)
+	RemoveNode(ctx context.Context, path ypath.YPath, options *yt.RemoveNodeOptions) error
+	GetNode(ctx context.Context, path ypath.YPath, result any, options *yt.GetNodeOptions) error
+	SetNode(ctx context.Context, path ypath.YPath, value any, options *yt.SetNodeOptions) error
+	MultisetAttributes(ctx context.Context, path ypath.YPath, attributes map[string]any, options *yt.MultisetAttributesOptions) error
+	ListNode(ctx context.Context, path ypath.YPath, result any, options *yt.ListNodeOptions) error
+	AddMember(ctx context.Context, group string, member string, options *yt.AddMemberOptions) error
+	RemoveMember(ctx context.Context, group string, member string, options *yt.RemoveMemberOptions) error
+	Stop()
+}
+
 type Ytsaurus struct {
-	client yt.Client
+	client ytsaurusClient
+	// transport, proxy, rpcProxy and secret are used for client rebuild on the secret rotation.
+	transport YtsaurusTransport
+	proxy     string
+	rpcProxy  string
+	secret    *secretReader
 
 	logger  appLoggerType
 	timeout time.Duration
@@ -34,7 +67,13 @@ type Ytsaurus struct {
 	debugUsernames  []string
 	debugGroupnames []string
 
-	sourceAttributeName string
+	sourceAttributeName  string
+	membershipsStorePath string
+	aclGrantsStatePath   string
+	userAttributes       UserAttributesConfig
+	homeDirectories      HomeDirectoriesConfig
+	groupAccounts        GroupAccountsConfig
+	groupPools           GroupPoolsConfig
 }
 
 func NewYtsaurus(cfg *YtsaurusConfig, logger appLoggerType, clock clock.PassiveClock) (*Ytsaurus, error) {
@@ -48,27 +87,68 @@ func NewYtsaurus(cfg *YtsaurusConfig, logger appLoggerType, clock clock.PassiveC
 	if cfg.SecretEnvVar == "" {
 		cfg.SecretEnvVar = defaultYtsaurusSecretEnvVar
 	}
-	secret := os.Getenv(cfg.SecretEnvVar)
-	if secret == "" {
-		return nil, errors.Errorf("YTsaurus secret in %s env var shouldn't be empty", cfg.SecretEnvVar)
+	err := validateYtsaurusTransport(cfg.Transport)
+	if err != nil {
+		return nil, err
 	}
-	client, err := ythttp.NewClient(&yt.Config{
-		Proxy: cfg.Proxy,
-		Credentials: &yt.TokenCredentials{
-			Token: secret,
-		},
-	})
+
+	err = validateHomeDirectoriesConfig(&cfg.HomeDirectories)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid home directories config")
+	}
+
+	err = validateGroupAccountsConfig(&cfg.GroupAccounts)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid group accounts config")
+	}
+
+	err = validateGroupPoolsConfig(&cfg.GroupPools)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid group pools config")
+	}
+
+	secret := newSecretReader(cfg.SecretEnvVar, cfg.SecretFile)
+	token, err := secret.read()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read YTsaurus secret")
+	}
+	client, err := newYtsaurusClient(cfg.Transport, cfg.Proxy, cfg.RPCProxy, token)
 	if err != nil {
 		return nil, err
 	}
+	ytsaurus := newYtsaurusWithClient(cfg, client, logger, clock)
+	ytsaurus.secret = secret
+	return ytsaurus, nil
+}
+
+// newYtsaurusWithClient is used in tests with YtsaurusFake client.
+func newYtsaurusWithClient(cfg *YtsaurusConfig, client ytsaurusClient, logger appLoggerType, clock clock.PassiveClock) *Ytsaurus {
 	if cfg.Timeout == 0 {
 		cfg.Timeout = defaultYtsaurusTimeout
 	}
 	if cfg.SourceAttributeName == "" {
 		cfg.SourceAttributeName = defaultSourceAttributeName
 	}
+	if len(cfg.HomeDirectories.Permissions) == 0 {
+		cfg.HomeDirectories.Permissions = defaultHomeDirectoryPermissions
+	}
+	if cfg.HomeDirectories.OnUserRemoval == "" {
+		cfg.HomeDirectories.OnUserRemoval = HomeDirectoryRemovalPolicyKeep
+	}
+	if cfg.GroupAccounts.OnGroupRemoval == "" {
+		cfg.GroupAccounts.OnGroupRemoval = GroupAccountRemovalPolicyKeep
+	}
+	if cfg.GroupPools.PoolTree == "" {
+		cfg.GroupPools.PoolTree = defaultPoolTree
+	}
+	if cfg.GroupPools.OnGroupRemoval == "" {
+		cfg.GroupPools.OnGroupRemoval = GroupPoolRemovalPolicyDetach
+	}
 	return &Ytsaurus{
 		client:        client,
+		transport:     cfg.Transport,
+		proxy:         cfg.Proxy,
+		rpcProxy:      cfg.RPCProxy,
 		dryRunUsers:   !cfg.ApplyUserChanges,
 		dryRunGroups:  !cfg.ApplyGroupChanges,
 		dryRunMembers: !cfg.ApplyMemberChanges,
@@ -77,24 +157,80 @@ func NewYtsaurus(cfg *YtsaurusConfig, logger appLoggerType, clock clock.PassiveC
 		timeout: cfg.Timeout,
 		clock:   clock,
 
-		debugUsernames:      cfg.DebugUsernames,
-		debugGroupnames:     cfg.DebugGroupnames,
-		sourceAttributeName: cfg.SourceAttributeName,
-	}, nil
+		debugUsernames:       cfg.DebugUsernames,
+		debugGroupnames:      cfg.DebugGroupnames,
+		sourceAttributeName:  cfg.SourceAttributeName,
+		membershipsStorePath: cfg.MembershipsStorePath,
+		aclGrantsStatePath:   cfg.ACLGrantsStatePath,
+		userAttributes:       cfg.UserAttributes,
+		homeDirectories:      cfg.HomeDirectories,
+		groupAccounts:        cfg.GroupAccounts,
+		groupPools:           cfg.GroupPools,
+	}
+}
+
+func validateYtsaurusTransport(transport YtsaurusTransport) error {
+	switch transport {
+	case "", YtsaurusTransportHTTP, YtsaurusTransportRPC:
+		return nil
+	}
+	return errors.Errorf("unknown YTsaurus transport %q", transport)
+}
+
+func newYtsaurusClient(transport YtsaurusTransport, proxy, rpcProxy, token string) (ytsaurusClient, error) {
+	config := &yt.Config{
+		Proxy: proxy,
+		Credentials: &yt.TokenCredentials{
+			Token: token,
+		},
+	}
+	if transport == YtsaurusTransportRPC {
+		config.RPCProxy = rpcProxy
+		return ytrpc.NewClient(config)
+	}
+	return ythttp.NewClient(config)
+}
+
+// ReloadSecretIfChanged rebuilds YTsaurus client if the token file has changed.
+func (y *Ytsaurus) ReloadSecretIfChanged() error {
+	if y.secret == nil {
+		return nil
+	}
+	token, changed, err := y.secret.readIfChanged()
+	if err != nil {
+		return errors.Wrap(err, "failed to reload YTsaurus secret")
+	}
+	if !changed {
+		return nil
+	}
+	client, err := newYtsaurusClient(y.transport, y.proxy, y.rpcProxy, token)
+	if err != nil {
+		return err
+	}
+	y.client.Stop()
+	y.client = client
+	y.logger.Info("YTsaurus secret has changed, client is rebuilt")
+	return nil
 }
 
 func (y *Ytsaurus) GetUsers() ([]YtsaurusUser, error) {
+	managedUsers, _, err := y.getUsersSplitByManagement()
+	return managedUsers, err
+}
+
+// getUsersSplitByManagement returns managed users and unmanaged ones (system or manually created users).
+func (y *Ytsaurus) getUsersSplitByManagement() (managedUsers, unmanagedUsers []YtsaurusUser, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
 	defer cancel()
 
-	users, err := doGetAllYtsaurusUsers(ctx, y.client, y.sourceAttributeName)
+	users, err := doGetAllYtsaurusUsers(ctx, y.client, y.sourceAttributeName, y.userAttributes)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get ytsaurus users")
+		return nil, nil, errors.Wrap(err, "failed to get ytsaurus users")
 	}
-	var managedUsers []YtsaurusUser
 	for _, user := range users {
 		y.maybePrintExtraLogs(user.Username, "get_user", "user", user)
 		if user.IsManuallyManaged() {
+			unmanagedUsers = append(unmanagedUsers, user)
 			continue
 		}
 		managedUsers = append(managedUsers, user)
@@ -103,7 +239,7 @@ func (y *Ytsaurus) GetUsers() ([]YtsaurusUser, error) {
 		"total", len(users),
 		"managed", len(managedUsers),
 	)
-	return managedUsers, nil
+	return managedUsers, unmanagedUsers, nil
 }
 
 func (y *Ytsaurus) CreateUser(user YtsaurusUser) error {
@@ -118,13 +254,15 @@ func (y *Ytsaurus) CreateUser(user YtsaurusUser) error {
 
 	y.maybePrintExtraLogs(user.Username, "create_user", "user", user)
 
+	attrs := map[string]any{
+		y.sourceAttributeName: user.SourceRaw,
+	}
+	addUserProfileAttributes(attrs, user, y.userAttributes)
 	return doCreateYtsaurusUser(
 		ctx,
 		y.client,
 		user.Username,
-		map[string]any{
-			y.sourceAttributeName: user.SourceRaw,
-		},
+		attrs,
 	)
 }
 
@@ -151,7 +289,7 @@ func (y *Ytsaurus) UpdateUser(username string, user YtsaurusUser) error {
 		ctx,
 		y.client,
 		username,
-		buildUserAttributes(user, y.sourceAttributeName),
+		buildUserAttributes(user, y.sourceAttributeName, y.userAttributes),
 	)
 }
 
@@ -177,11 +315,13 @@ func (y *Ytsaurus) RemoveUser(username string) error {
 	)
 }
 
-func (y *Ytsaurus) BanUser(username string) error {
+// BanUser bans the user recording the reason, the sync cycle ID and the scheduled removal time
+// (zero removeAfter means removal is not scheduled).
+func (y *Ytsaurus) BanUser(username string, reason BanReason, cycleID string, removeAfter time.Time) error {
 	if err := y.ensureUserManaged(username); err != nil {
 		return err
 	}
-	logger := y.logger.With("username", username)
+	logger := y.logger.With("username", username, "reason", reason, "cycle_id", cycleID, "remove_after", removeAfter)
 	if y.dryRunUsers {
 		logger.Debugw("[DRY-RUN] Going to ban user")
 		return nil
@@ -197,24 +337,63 @@ func (y *Ytsaurus) BanUser(username string) error {
 		y.client,
 		username,
 		map[string]any{
-			"banned":       true,
-			"banned_since": y.clock.Now().UTC().Format(appTimeFormat),
+			bannedAttributeName:      true,
+			bannedSinceAttributeName: formatAppTime(y.clock.Now()),
+			banReasonAttributeName:   string(reason),
+			banCycleIDAttributeName:  cycleID,
+			removeAfterAttributeName: formatAppTime(removeAfter),
+		},
+	)
+}
+
+// UnbanUser lifts the ban from the user and clears the ban metadata.
+func (y *Ytsaurus) UnbanUser(username string) error {
+	if err := y.ensureUserManaged(username); err != nil {
+		return err
+	}
+	logger := y.logger.With("username", username)
+	if y.dryRunUsers {
+		logger.Debugw("[DRY-RUN] Going to unban user")
+		return nil
+	}
+	logger.Debugw("Going to unban user")
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	y.maybePrintExtraLogs(username, "unban_user", "username", username)
+	return doSetAttributesForYtsaurusUser(
+		ctx,
+		y.client,
+		username,
+		map[string]any{
+			bannedAttributeName:      false,
+			bannedSinceAttributeName: "",
+			banReasonAttributeName:   "",
+			banCycleIDAttributeName:  "",
+			removeAfterAttributeName: "",
 		},
 	)
 }
 
 func (y *Ytsaurus) GetGroupsWithMembers() ([]YtsaurusGroupWithMembers, error) {
+	managedGroups, _, err := y.getGroupsSplitByManagement()
+	return managedGroups, err
+}
+
+// getGroupsSplitByManagement returns managed groups and unmanaged ones (system or manually created groups).
+func (y *Ytsaurus) getGroupsSplitByManagement() (managedGroups, unmanagedGroups []YtsaurusGroupWithMembers, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
 	defer cancel()
 
 	groups, err := doGetAllYtsaurusGroupsWithMembers(ctx, y.client, y.sourceAttributeName)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get ytsaurus groups")
+		return nil, nil, errors.Wrap(err, "failed to get ytsaurus groups")
 	}
-	var managedGroups []YtsaurusGroupWithMembers
 	for _, group := range groups {
 		y.maybePrintExtraLogs(group.Name, "get_group", "group", group)
 		if group.IsManuallyManaged() {
+			unmanagedGroups = append(unmanagedGroups, group)
 			continue
 		}
 		managedGroups = append(managedGroups, group)
@@ -223,7 +402,7 @@ func (y *Ytsaurus) GetGroupsWithMembers() ([]YtsaurusGroupWithMembers, error) {
 		"total", len(groups),
 		"managed", len(managedGroups),
 	)
-	return managedGroups, nil
+	return managedGroups, unmanagedGroups, nil
 }
 
 func (y *Ytsaurus) CreateGroup(group YtsaurusGroup) error {
@@ -337,6 +516,75 @@ func (y *Ytsaurus) RemoveMember(username, groupname string) error {
 	return doRemoveMemberYtsaurusGroup(ctx, y.client, username, groupname)
 }
 
+// RemoveUnmanagedMember removes the member, which is not managed by the app (manually created user
+// or nested group), from the managed group.
+func (y *Ytsaurus) RemoveUnmanagedMember(member, groupname string) error {
+	if y.dryRunMembers {
+		y.logger.Debugw("[DRY-RUN] Going to remove unmanaged member", "member", member, "groupname", groupname)
+		return nil
+	}
+	if err := y.ensureGroupManaged(groupname); err != nil {
+		return err
+	}
+	y.logger.Debugw("Going to remove unmanaged member", "member", member, "groupname", groupname)
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	y.maybePrintExtraLogs(groupname, "remove_unmanaged_member", "member", member, "groupname", groupname)
+	return doRemoveMemberYtsaurusGroup(ctx, y.client, member, groupname)
+}
+
+// AdoptUser sets source attribute for the existing manually created user, so it becomes managed.
+func (y *Ytsaurus) AdoptUser(user YtsaurusUser) error {
+	if err := y.ensureUserUnmanaged(user.Username); err != nil {
+		return err
+	}
+	if y.dryRunUsers {
+		y.logger.Debugw("[DRY-RUN] Going to adopt user", "user", user)
+		return nil
+	}
+	y.logger.Debugw("Going to adopt user", "user", user)
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	y.maybePrintExtraLogs(user.Username, "adopt_user", "user", user)
+	return doSetAttributesForYtsaurusUser(
+		ctx,
+		y.client,
+		user.Username,
+		map[string]any{
+			y.sourceAttributeName: user.SourceRaw,
+		},
+	)
+}
+
+// AdoptGroup sets source attribute for the existing manually created group, so it becomes managed.
+func (y *Ytsaurus) AdoptGroup(group YtsaurusGroup) error {
+	if err := y.ensureGroupUnmanaged(group.Name); err != nil {
+		return err
+	}
+	if y.dryRunGroups {
+		y.logger.Debugw("[DRY-RUN] Going to adopt group", "group", group)
+		return nil
+	}
+	y.logger.Debugw("Going to adopt group", "group", group)
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	y.maybePrintExtraLogs(group.Name, "adopt_group", "group", group)
+	return doSetAttributesForYtsaurusGroup(
+		ctx,
+		y.client,
+		group.Name,
+		map[string]any{
+			y.sourceAttributeName: group.SourceRaw,
+		},
+	)
+}
+
 func (y *Ytsaurus) isUserManaged(username string) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
 	defer cancel()
@@ -359,6 +607,17 @@ func (y *Ytsaurus) ensureUserManaged(username string) error {
 	return nil
 }
 
+func (y *Ytsaurus) ensureUserUnmanaged(username string) error {
+	isManaged, err := y.isUserManaged(username)
+	if err != nil {
+		return errors.Wrap(err, "Failed to check if user is managed")
+	}
+	if isManaged {
+		return errors.New("Prevented attempt to adopt already managed user " + username)
+	}
+	return nil
+}
+
 func (y *Ytsaurus) isGroupManaged(name string) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
 	defer cancel()
@@ -381,6 +640,17 @@ func (y *Ytsaurus) ensureGroupManaged(groupname string) error {
 	return nil
 }
 
+func (y *Ytsaurus) ensureGroupUnmanaged(groupname string) error {
+	isManaged, err := y.isGroupManaged(groupname)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to check if group %s is managed", groupname)
+	}
+	if isManaged {
+		return errors.New("Prevented attempt to adopt already managed group " + groupname)
+	}
+	return nil
+}
+
 func (y *Ytsaurus) maybePrintExtraLogs(name string, event string, args ...any) {
 	args = append([]any{"debug_name", name, "event", event}, args...)
 	for _, debugID := range y.debugUsernames {
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"go.ytsaurus.tech/yt/go/ypath"
+	"go.ytsaurus.tech/yt/go/yt"
+)
+
+// GroupAccountRemovalPolicy defines what happens with the group account when its group is removed.
+type GroupAccountRemovalPolicy string
+
+const (
+	// GroupAccountRemovalPolicyKeep leaves the account as is (default), it is reused if the group returns.
+	GroupAccountRemovalPolicyKeep GroupAccountRemovalPolicy = "keep"
+	// GroupAccountRemovalPolicyRemove removes the account, YTsaurus fails removal of non-empty accounts.
+	GroupAccountRemovalPolicyRemove GroupAccountRemovalPolicy = "remove"
+
+	accountsPath                = "//sys/accounts"
+	parentNameAttributeName     = "parent_name"
+	resourceLimitsAttributeName = "resource_limits"
+	aclAttributeName            = "acl"
+)
+
+func validateGroupAccountsConfig(cfg *GroupAccountsConfig) error {
+	switch cfg.OnGroupRemoval {
+	case "", GroupAccountRemovalPolicyKeep, GroupAccountRemovalPolicyRemove:
+		return nil
+	}
+	return errors.Errorf("unknown group account removal policy %q", cfg.OnGroupRemoval)
+}
+
+func (y *Ytsaurus) IsGroupAccountsEnabled() bool {
+	return y.groupAccounts.Enabled
+}
+
+func (y *Ytsaurus) IsGroupAccountRemovalEnabled() bool {
+	return y.groupAccounts.OnGroupRemoval == GroupAccountRemovalPolicyRemove
+}
+
+func (y *Ytsaurus) groupAccountPath(name string) ypath.Path {
+	return ypath.Path(accountsPath).Child(name)
+}
+
+// GetGroupAccounts returns accounts created for managed groups (having the source attribute).
+func (y *Ytsaurus) GetGroupAccounts() ([]YtsaurusGroupAccount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	objects, err := doGetAllYtsaurusObjectsAttributes(ctx, y.client, ypath.Path(accountsPath), []string{y.sourceAttributeName})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get ytsaurus accounts")
+	}
+	var accounts []YtsaurusGroupAccount
+	for name, attrs := range objects {
+		sourceRaw, ok := attrs[y.sourceAttributeName].(map[string]any)
+		if !ok {
+			continue
+		}
+		account := YtsaurusGroupAccount{Name: name, SourceRaw: sourceRaw}
+		y.maybePrintExtraLogs(name, "get_group_account", "account", account)
+		accounts = append(accounts, account)
+	}
+	y.logger.Infow("Fetched all accounts from YTsaurus",
+		"total", len(objects),
+		"managed", len(accounts),
+	)
+	return accounts, nil
+}
+
+// CreateGroupAccount creates the account with default resource limits and grants `use` to the group.
+func (y *Ytsaurus) CreateGroupAccount(account YtsaurusGroupAccount) error {
+	logger := y.logger.With("account", account.Name)
+	attrs := map[string]any{
+		nameAttributeName:     account.Name,
+		y.sourceAttributeName: account.SourceRaw,
+		aclAttributeName: []yt.ACE{{
+			Action:      yt.ActionAllow,
+			Subjects:    []string{account.Name},
+			Permissions: []yt.Permission{yt.PermissionUse},
+		}},
+	}
+	if y.groupAccounts.ParentName != "" {
+		attrs[parentNameAttributeName] = y.groupAccounts.ParentName
+	}
+	if len(y.groupAccounts.ResourceLimits) > 0 {
+		attrs[resourceLimitsAttributeName] = y.groupAccounts.ResourceLimits
+	}
+
+	if y.dryRunGroups {
+		logger.Debugw("[DRY-RUN] Going to create group account", "attributes", attrs)
+		return nil
+	}
+	logger.Debugw("Going to create group account", "attributes", attrs)
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	y.maybePrintExtraLogs(account.Name, "create_group_account", "attributes", attrs)
+	_, err := y.client.CreateObject(ctx, yt.NodeAccount, &yt.CreateObjectOptions{Attributes: attrs})
+	return err
+}
+
+// UpdateGroupAccount renames the account after its group and updates its source attribute.
+// Resource limits and ACL are not touched. The name in @acl follows the group rename in YTsaurus.
+func (y *Ytsaurus) UpdateGroupAccount(name string, account YtsaurusGroupAccount) error {
+	logger := y.logger.With("account", name, "new_name", account.Name)
+	if y.dryRunGroups {
+		logger.Debugw("[DRY-RUN] Going to update group account")
+		return nil
+	}
+	logger.Debugw("Going to update group account")
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	attrs := map[string]any{y.sourceAttributeName: account.SourceRaw}
+	if account.Name != name {
+		attrs[nameAttributeName] = account.Name
+	}
+	y.maybePrintExtraLogs(name, "update_group_account", "account", account)
+	return y.client.MultisetAttributes(ctx, y.groupAccountPath(name).Attrs(), attrs, nil)
+}
+
+func (y *Ytsaurus) RemoveGroupAccount(name string) error {
+	logger := y.logger.With("account", name)
+	if y.dryRunGroups {
+		logger.Debugw("[DRY-RUN] Going to remove group account")
+		return nil
+	}
+	logger.Debugw("Going to remove group account")
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	y.maybePrintExtraLogs(name, "remove_group_account", "account", name)
+	return y.client.RemoveNode(ctx, y.groupAccountPath(name), nil)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"go.ytsaurus.tech/yt/go/ypath"
+	"go.ytsaurus.tech/yt/go/yt"
+)
+
+// ownedACE is an ACE added by the app. It is recorded with the source id of its group,
+// so it is matched with @acl, which follows group renames, regardless of the name it was recorded with.
+type ownedACE struct {
+	SourceGroupID ObjectID `yson:"source_group_id"`
+	ACE           yt.ACE   `yson:"ace"`
+}
+
+// ownedACLAttributeName is a name of the node attribute, where ACEs added by the app are recorded,
+// so they can be told apart from manually added ones.
+func (y *Ytsaurus) ownedACLAttributeName() string {
+	return y.sourceAttributeName + "_acl"
+}
+
+// GetACL returns @acl of the node and ACEs owned by the app.
+func (y *Ytsaurus) GetACL(path string) (acl []yt.ACE, owned []ownedACE, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	err = y.client.GetNode(ctx, ypath.Path(path).Attr(aclAttributeName), &acl, nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get acl")
+	}
+	ownedPath := ypath.Path(path).Attr(y.ownedACLAttributeName())
+	exists, err := y.client.NodeExists(ctx, ownedPath, nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to check owned acl")
+	}
+	if exists {
+		err = y.client.GetNode(ctx, ownedPath, &owned, nil)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to get owned acl")
+		}
+	}
+	return acl, owned, nil
+}
+
+// SetACL sets @acl of the node and records ACEs owned by the app.
+func (y *Ytsaurus) SetACL(path string, acl []yt.ACE, owned []ownedACE) error {
+	logger := y.logger.With("path", path, "acl", acl, "owned", owned)
+	if y.dryRunGroups {
+		logger.Debugw("[DRY-RUN] Going to set acl")
+		return nil
+	}
+	logger.Debugw("Going to set acl")
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	return y.client.MultisetAttributes(
+		ctx,
+		ypath.Path(path).Attrs(),
+		map[string]any{
+			aclAttributeName:          acl,
+			y.ownedACLAttributeName(): owned,
+		},
+		nil,
+	)
+}
+
+// GetOwnedACLPaths returns paths of nodes with owned ACEs recorded in the acl grants state.
+func (y *Ytsaurus) GetOwnedACLPaths() ([]string, error) {
+	if y.aclGrantsStatePath == "" {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	exists, err := y.client.NodeExists(ctx, ypath.Path(y.aclGrantsStatePath), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check acl grants state")
+	}
+	if !exists {
+		return nil, nil
+	}
+	var paths []string
+	err = y.client.GetNode(ctx, ypath.Path(y.aclGrantsStatePath), &paths, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get acl grants state")
+	}
+	return paths, nil
+}
+
+// SetOwnedACLPaths records paths of nodes with owned ACEs in the acl grants state,
+// so ACEs are revoked after their paths are removed from the config.
+func (y *Ytsaurus) SetOwnedACLPaths(paths []string) error {
+	if y.aclGrantsStatePath == "" {
+		return errors.New("acl grants state path is not configured")
+	}
+	logger := y.logger.With("paths", paths)
+	if y.dryRunGroups {
+		logger.Debugw("[DRY-RUN] Going to record owned acl paths")
+		return nil
+	}
+	logger.Debugw("Going to record owned acl paths")
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	return y.client.SetNode(
+		ctx,
+		ypath.Path(y.aclGrantsStatePath),
+		paths,
+		&yt.SetNodeOptions{Recursive: true, Force: true},
+	)
+}
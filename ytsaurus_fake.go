@@ -0,0 +1,919 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.ytsaurus.tech/yt/go/guid"
+	"go.ytsaurus.tech/yt/go/ypath"
+	"go.ytsaurus.tech/yt/go/yson"
+	"go.ytsaurus.tech/yt/go/yt"
+	"go.ytsaurus.tech/yt/go/yterrors"
+)
+
+var (
+	ytsaurusFakeBuiltinUsers = []string{
+		"root",
+		"guest",
+		"job",
+		"scheduler",
+		"replicator",
+		"file_cache",
+		"operations_cleaner",
+		"operations_client",
+		"tablet_cell_changelogger",
+		"tablet_cell_snapshotter",
+		"table_mount_informer",
+		"tablet_balancer",
+		"alien_cell_synchronizer",
+		"queue_agent",
+	}
+	ytsaurusFakeBuiltinGroups = map[string][]string{
+		"everyone":         nil,
+		"users":            {"superusers"},
+		"superusers":       {"root", "scheduler", "job", "replicator", "file_cache", "operations_cleaner"},
+		"admins":           nil,
+		"admin_snapshots":  nil,
+		"replicator_users": nil,
+	}
+	ytsaurusFakeBuiltinAccounts = []string{"sys", "tmp", "intermediate"}
+	// ytsaurusFakeBuiltinAttributes are computed by the fake and can't be set directly.
+	ytsaurusFakeBuiltinAttributes = NewStringSetFromItems("type", "members", "member_of")
+)
+
+// ytsaurusFakeNode is a Cypress node: map node, document or object (user, group, account, pool tree, pool).
+type ytsaurusFakeNode struct {
+	typ      yt.NodeType
+	name     string
+	parent   *ytsaurusFakeNode
+	value    any
+	attrs    map[string]any
+	children map[string]*ytsaurusFakeNode
+	// members are names of group members in the order of addition.
+	members []string
+}
+
+// YtsaurusFake is an in-memory implementation of ytsaurusClient for tests without YTsaurus cluster.
+// It imitates YTsaurus semantics and errors of the used methods: builtin users and groups exist
+// from the start, created users become members of the `users` group, users and groups are stored in
+// //sys/users and //sys/groups and renamed on @name change, removed subjects leave all groups.
+// Accounts are stored in //sys/accounts, @parent_name of created account should exist.
+// Pool trees are stored in //sys/pool_trees, pools are nested into their pool trees and parent pools.
+type YtsaurusFake struct {
+	mu   sync.Mutex
+	root *ytsaurusFakeNode
+}
+
+func NewYtsaurusFake() *YtsaurusFake {
+	f := &YtsaurusFake{root: newYtsaurusFakeMapNode("", nil)}
+	sys := f.root.addChild(newYtsaurusFakeMapNode("sys", f.root))
+	users := sys.addChild(newYtsaurusFakeMapNode("users", sys))
+	groups := sys.addChild(newYtsaurusFakeMapNode("groups", sys))
+	accounts := sys.addChild(newYtsaurusFakeMapNode("accounts", sys))
+	sys.addChild(newYtsaurusFakeMapNode("pool_trees", sys))
+	f.root.addChild(newYtsaurusFakeMapNode("tmp", f.root))
+
+	for _, username := range ytsaurusFakeBuiltinUsers {
+		users.addChild(newYtsaurusFakeObject(yt.NodeUser, username, users))
+	}
+	for groupname, members := range ytsaurusFakeBuiltinGroups {
+		group := groups.addChild(newYtsaurusFakeObject(yt.NodeGroup, groupname, groups))
+		group.members = append(group.members, members...)
+	}
+	for _, account := range ytsaurusFakeBuiltinAccounts {
+		accounts.addChild(newYtsaurusFakeObject(yt.NodeAccount, account, accounts))
+	}
+	return f
+}
+
+func newYtsaurusFakeMapNode(name string, parent *ytsaurusFakeNode) *ytsaurusFakeNode {
+	return &ytsaurusFakeNode{
+		typ:      yt.NodeMap,
+		name:     name,
+		parent:   parent,
+		attrs:    make(map[string]any),
+		children: make(map[string]*ytsaurusFakeNode),
+	}
+}
+
+func newYtsaurusFakeObject(typ yt.NodeType, name string, parent *ytsaurusFakeNode) *ytsaurusFakeNode {
+	return &ytsaurusFakeNode{
+		typ:    typ,
+		name:   name,
+		parent: parent,
+		attrs:  make(map[string]any),
+	}
+}
+
+func (n *ytsaurusFakeNode) addChild(child *ytsaurusFakeNode) *ytsaurusFakeNode {
+	n.children[child.name] = child
+	return child
+}
+
+func (n *ytsaurusFakeNode) path() string {
+	if n.parent == nil {
+		return "/"
+	}
+	return strings.TrimSuffix(n.parent.path(), "/") + "/" + n.name
+}
+
+// ytsaurusFakePath is a parsed path: node path tokens and optional attribute path tokens.
+type ytsaurusFakePath struct {
+	raw      string
+	nodePath []string
+	isAttr   bool
+	attrName string
+	attrPath []string
+}
+
+func parseYtsaurusFakePath(path ypath.YPath) (*ytsaurusFakePath, error) {
+	raw := path.YPath().String()
+	if !strings.HasPrefix(raw, "//") && raw != "/" {
+		return nil, yterrors.Err(yterrors.CodeResolveError, fmt.Sprintf("Unexpected path %s", raw))
+	}
+	parsed := &ytsaurusFakePath{raw: raw}
+	for _, token := range strings.Split(strings.TrimPrefix(raw, "//"), "/") {
+		switch {
+		case parsed.isAttr:
+			parsed.attrPath = append(parsed.attrPath, token)
+		case strings.HasPrefix(token, "@"):
+			parsed.isAttr = true
+			parsed.attrName = strings.TrimPrefix(token, "@")
+		case token != "":
+			parsed.nodePath = append(parsed.nodePath, token)
+		}
+	}
+	return parsed, nil
+}
+
+func ytsaurusFakeResolveError(path string) error {
+	return yterrors.Err(yterrors.CodeResolveError, fmt.Sprintf("Error resolving path %s", path))
+}
+
+func (f *YtsaurusFake) findNode(nodePath []string) *ytsaurusFakeNode {
+	node := f.root
+	for _, name := range nodePath {
+		if node.children == nil {
+			return nil
+		}
+		node = node.children[name]
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+func (f *YtsaurusFake) resolveNode(path *ytsaurusFakePath) (*ytsaurusFakeNode, error) {
+	node := f.findNode(path.nodePath)
+	if node == nil {
+		return nil, ytsaurusFakeResolveError(path.raw)
+	}
+	return node, nil
+}
+
+func (f *YtsaurusFake) usersNode() *ytsaurusFakeNode {
+	return f.findNode([]string{"sys", "users"})
+}
+
+func (f *YtsaurusFake) groupsNode() *ytsaurusFakeNode {
+	return f.findNode([]string{"sys", "groups"})
+}
+
+func (f *YtsaurusFake) accountsNode() *ytsaurusFakeNode {
+	return f.findNode([]string{"sys", "accounts"})
+}
+
+func (f *YtsaurusFake) poolTreesNode() *ytsaurusFakeNode {
+	return f.findNode([]string{"sys", "pool_trees"})
+}
+
+// findPool searches the pool by name in the pool tree, pool names are unique within the tree.
+func findPool(node *ytsaurusFakeNode, name string) *ytsaurusFakeNode {
+	for childName, child := range node.children {
+		if childName == name {
+			return child
+		}
+		if pool := findPool(child, name); pool != nil {
+			return pool
+		}
+	}
+	return nil
+}
+
+// poolTree returns the pool tree of the pool.
+func (n *ytsaurusFakeNode) poolTree() *ytsaurusFakeNode {
+	node := n
+	for node != nil && node.typ != yt.NodeSchedulerPoolTree {
+		node = node.parent
+	}
+	return node
+}
+
+func (f *YtsaurusFake) findSubject(name string) *ytsaurusFakeNode {
+	if user := f.usersNode().children[name]; user != nil {
+		return user
+	}
+	return f.groupsNode().children[name]
+}
+
+func (f *YtsaurusFake) memberOf(name string) []any {
+	var groupnames []string
+	for groupname, group := range f.groupsNode().children {
+		for _, member := range group.members {
+			if member == name {
+				groupnames = append(groupnames, groupname)
+			}
+		}
+	}
+	sort.Strings(groupnames)
+	result := make([]any, 0, len(groupnames))
+	for _, groupname := range groupnames {
+		result = append(result, groupname)
+	}
+	return result
+}
+
+// normalizeYtsaurusFakeValue converts value to the generic form, which is returned by YTsaurus
+// (map[string]any, []any, int64, etc.), so stored values don't depend on the caller's types.
+func normalizeYtsaurusFakeValue(value any) (any, error) {
+	data, err := yson.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	err = yson.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+func decodeYtsaurusFakeValue(value any, result any) error {
+	data, err := yson.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return yson.Unmarshal(data, result)
+}
+
+func (f *YtsaurusFake) getAttribute(node *ytsaurusFakeNode, name string) (any, bool) {
+	switch name {
+	case "name":
+		return node.name, true
+	case "type":
+		return string(node.typ), true
+	case "path":
+		return node.path(), true
+	case "members":
+		if node.typ == yt.NodeGroup {
+			members := make([]any, 0, len(node.members))
+			for _, member := range node.members {
+				members = append(members, member)
+			}
+			return members, true
+		}
+	case "member_of":
+		if node.typ == yt.NodeUser || node.typ == yt.NodeGroup {
+			return f.memberOf(node.name), true
+		}
+	case "acl":
+		if _, ok := node.attrs[name]; !ok {
+			return []any{}, true
+		}
+	}
+	value, ok := node.attrs[name]
+	return value, ok
+}
+
+func (f *YtsaurusFake) getValue(path *ytsaurusFakePath) (any, error) {
+	node, err := f.resolveNode(path)
+	if err != nil {
+		return nil, err
+	}
+	if !path.isAttr {
+		return f.nodeValue(node), nil
+	}
+	if path.attrName == "" {
+		attrs := make(map[string]any)
+		for key := range node.attrs {
+			attrs[key], _ = f.getAttribute(node, key)
+		}
+		for _, key := range []string{"name", "type", "path", "members", "member_of"} {
+			if value, ok := f.getAttribute(node, key); ok {
+				attrs[key] = value
+			}
+		}
+		return attrs, nil
+	}
+	value, ok := f.getAttribute(node, path.attrName)
+	if !ok {
+		return nil, ytsaurusFakeResolveError(path.raw)
+	}
+	for _, key := range path.attrPath {
+		valueMap, isMap := value.(map[string]any)
+		if !isMap {
+			return nil, ytsaurusFakeResolveError(path.raw)
+		}
+		value, ok = valueMap[key]
+		if !ok {
+			return nil, ytsaurusFakeResolveError(path.raw)
+		}
+	}
+	return value, nil
+}
+
+func (f *YtsaurusFake) nodeValue(node *ytsaurusFakeNode) any {
+	switch node.typ {
+	case yt.NodeMap:
+		value := make(map[string]any)
+		for name, child := range node.children {
+			value[name] = f.nodeValue(child)
+		}
+		return value
+	case yt.NodeDocument:
+		return node.value
+	}
+	// Objects are entities without value.
+	return nil
+}
+
+func (f *YtsaurusFake) setAttribute(node *ytsaurusFakeNode, path *ytsaurusFakePath, value any) error {
+	if ytsaurusFakeBuiltinAttributes.Contains(path.attrName) {
+		return yterrors.Err(fmt.Sprintf("Builtin attribute %q cannot be set", path.attrName))
+	}
+	if path.attrName == "name" && len(path.attrPath) == 0 {
+		return f.rename(node, value)
+	}
+	normalized, err := normalizeYtsaurusFakeValue(value)
+	if err != nil {
+		return err
+	}
+	if len(path.attrPath) == 0 {
+		node.attrs[path.attrName] = normalized
+		return nil
+	}
+	current, ok := node.attrs[path.attrName].(map[string]any)
+	if !ok {
+		return ytsaurusFakeResolveError(path.raw)
+	}
+	for _, key := range path.attrPath[:len(path.attrPath)-1] {
+		current, ok = current[key].(map[string]any)
+		if !ok {
+			return ytsaurusFakeResolveError(path.raw)
+		}
+	}
+	current[path.attrPath[len(path.attrPath)-1]] = normalized
+	return nil
+}
+
+// rename imitates @name change of users, groups and accounts, as YTsaurus it fails even if the name is the same.
+func (f *YtsaurusFake) rename(node *ytsaurusFakeNode, value any) error {
+	if node.typ != yt.NodeUser && node.typ != yt.NodeGroup && node.typ != yt.NodeAccount && node.typ != yt.NodeSchedulerPool {
+		return yterrors.Err(fmt.Sprintf("Builtin attribute \"name\" cannot be set for %s", node.typ))
+	}
+	newName, ok := value.(string)
+	if !ok || newName == "" {
+		return yterrors.Err(fmt.Sprintf("Invalid name %v", value))
+	}
+	exists := f.findSubject(newName) != nil
+	switch node.typ {
+	case yt.NodeAccount:
+		exists = f.accountsNode().children[newName] != nil
+	case yt.NodeSchedulerPool:
+		exists = findPool(node.poolTree(), newName) != nil
+	}
+	if exists {
+		return yterrors.Err(
+			yterrors.CodeAlreadyExists,
+			fmt.Sprintf("Error setting builtin attribute \"name\": %s %q already exists", node.typ, newName),
+		)
+	}
+	oldName := node.name
+	delete(node.parent.children, oldName)
+	node.name = newName
+	node.parent.addChild(node)
+	for _, group := range f.groupsNode().children {
+		for idx, member := range group.members {
+			if member == oldName {
+				group.members[idx] = newName
+			}
+		}
+	}
+	if node.typ == yt.NodeUser || node.typ == yt.NodeGroup {
+		renameYtsaurusFakeACLSubject(f.root, oldName, newName)
+	}
+	return nil
+}
+
+// renameYtsaurusFakeACLSubject imitates ACEs referencing subjects by id, so @acl follows subject renames.
+func renameYtsaurusFakeACLSubject(node *ytsaurusFakeNode, oldName, newName string) {
+	acl, _ := node.attrs["acl"].([]any)
+	for _, ace := range acl {
+		aceMap, _ := ace.(map[string]any)
+		subjects, _ := aceMap["subjects"].([]any)
+		for idx, subject := range subjects {
+			if subject == oldName {
+				subjects[idx] = newName
+			}
+		}
+	}
+	for _, child := range node.children {
+		renameYtsaurusFakeACLSubject(child, oldName, newName)
+	}
+}
+
+func (f *YtsaurusFake) CreateObject(ctx context.Context, typ yt.NodeType, options *yt.CreateObjectOptions) (yt.NodeID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	attrs := make(map[string]any)
+	if options != nil {
+		for key, value := range options.Attributes {
+			attrs[key] = value
+		}
+	}
+	name, _ := attrs["name"].(string)
+	if name == "" {
+		return yt.NodeID{}, yterrors.Err(fmt.Sprintf("Attribute \"name\" is required for %s creation", typ))
+	}
+	delete(attrs, "name")
+
+	var parent *ytsaurusFakeNode
+	exists := f.findSubject(name) != nil
+	switch typ {
+	case yt.NodeUser:
+		parent = f.usersNode()
+	case yt.NodeGroup:
+		parent = f.groupsNode()
+	case yt.NodeAccount:
+		parent = f.accountsNode()
+		exists = parent.children[name] != nil
+		parentName, _ := attrs["parent_name"].(string)
+		if parentName != "" && parent.children[parentName] == nil {
+			return yt.NodeID{}, yterrors.Err(fmt.Sprintf("No such account %q", parentName))
+		}
+	case yt.NodeSchedulerPoolTree:
+		parent = f.poolTreesNode()
+		exists = parent.children[name] != nil
+	case yt.NodeSchedulerPool:
+		poolTree, _ := attrs["pool_tree"].(string)
+		parent = f.poolTreesNode().children[poolTree]
+		if parent == nil {
+			return yt.NodeID{}, yterrors.Err(fmt.Sprintf("Pool tree %q does not exist", poolTree))
+		}
+		delete(attrs, "pool_tree")
+		exists = findPool(parent, name) != nil
+		if parentName, _ := attrs["parent_name"].(string); parentName != "" {
+			parent = findPool(parent, parentName)
+			if parent == nil {
+				return yt.NodeID{}, yterrors.Err(fmt.Sprintf("Pool %q does not exist", parentName))
+			}
+			delete(attrs, "parent_name")
+		}
+	default:
+		return yt.NodeID{}, yterrors.Err(fmt.Sprintf("Object type %s is not supported by the fake", typ))
+	}
+	if exists {
+		return yt.NodeID{}, yterrors.Err(
+			yterrors.CodeAlreadyExists,
+			fmt.Sprintf("%s %q already exists", typ, name),
+		)
+	}
+
+	object := newYtsaurusFakeObject(typ, name, parent)
+	if typ == yt.NodeSchedulerPoolTree || typ == yt.NodeSchedulerPool {
+		object.children = make(map[string]*ytsaurusFakeNode)
+	}
+	for key, value := range attrs {
+		err := f.setAttribute(object, &ytsaurusFakePath{raw: key, isAttr: true, attrName: key}, value)
+		if err != nil {
+			return yt.NodeID{}, err
+		}
+	}
+	parent.addChild(object)
+	if typ == yt.NodeUser {
+		usersGroup := f.groupsNode().children["users"]
+		usersGroup.members = append(usersGroup.members, name)
+	}
+	return yt.NodeID(guid.New()), nil
+}
+
+func (f *YtsaurusFake) NodeExists(ctx context.Context, path ypath.YPath, options *yt.NodeExistsOptions) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parsed, err := parseYtsaurusFakePath(path)
+	if err != nil {
+		return false, err
+	}
+	_, err = f.getValue(parsed)
+	if yterrors.ContainsResolveError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (f *YtsaurusFake) RemoveNode(ctx context.Context, path ypath.YPath, options *yt.RemoveNodeOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if options == nil {
+		options = &yt.RemoveNodeOptions{}
+	}
+	parsed, err := parseYtsaurusFakePath(path)
+	if err != nil {
+		return err
+	}
+	node := f.findNode(parsed.nodePath)
+	if node == nil {
+		if options.Force {
+			return nil
+		}
+		return ytsaurusFakeResolveError(parsed.raw)
+	}
+
+	if parsed.isAttr {
+		return f.removeAttribute(node, parsed, options.Force)
+	}
+
+	switch node.typ {
+	case yt.NodeUser, yt.NodeGroup:
+		if builtinSubjectNames.Contains(node.name) {
+			return yterrors.Err(fmt.Sprintf("Cannot remove a builtin %s %q", node.typ, node.name))
+		}
+		f.removeFromAllGroups(node.name)
+	case yt.NodeMap:
+		if node.parent == nil || len(parsed.nodePath) <= 2 && parsed.nodePath[0] == "sys" {
+			return yterrors.Err(fmt.Sprintf("Cannot remove system node %s", parsed.raw))
+		}
+		if len(node.children) > 0 && !options.Recursive {
+			return yterrors.Err(fmt.Sprintf("Cannot remove non-empty composite node %s", parsed.raw))
+		}
+	}
+	delete(node.parent.children, node.name)
+	return nil
+}
+
+func (f *YtsaurusFake) removeAttribute(node *ytsaurusFakeNode, path *ytsaurusFakePath, force bool) error {
+	if path.attrName == "" || ytsaurusFakeBuiltinAttributes.Contains(path.attrName) || path.attrName == "name" {
+		return yterrors.Err(fmt.Sprintf("Builtin attribute %q cannot be removed", path.attrName))
+	}
+	if len(path.attrPath) == 0 {
+		if _, ok := node.attrs[path.attrName]; !ok && !force {
+			return ytsaurusFakeResolveError(path.raw)
+		}
+		delete(node.attrs, path.attrName)
+		return nil
+	}
+	current, ok := node.attrs[path.attrName].(map[string]any)
+	for _, key := range path.attrPath[:len(path.attrPath)-1] {
+		if !ok {
+			break
+		}
+		current, ok = current[key].(map[string]any)
+	}
+	lastKey := path.attrPath[len(path.attrPath)-1]
+	if !ok || current[lastKey] == nil {
+		if force {
+			return nil
+		}
+		return ytsaurusFakeResolveError(path.raw)
+	}
+	delete(current, lastKey)
+	return nil
+}
+
+func (f *YtsaurusFake) removeFromAllGroups(name string) {
+	for _, group := range f.groupsNode().children {
+		var members []string
+		for _, member := range group.members {
+			if member != name {
+				members = append(members, member)
+			}
+		}
+		group.members = members
+	}
+}
+
+func (f *YtsaurusFake) GetNode(ctx context.Context, path ypath.YPath, result any, options *yt.GetNodeOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parsed, err := parseYtsaurusFakePath(path)
+	if err != nil {
+		return err
+	}
+	value, err := f.getValue(parsed)
+	if err != nil {
+		return err
+	}
+	return decodeYtsaurusFakeValue(value, result)
+}
+
+func (f *YtsaurusFake) SetNode(ctx context.Context, path ypath.YPath, value any, options *yt.SetNodeOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if options == nil {
+		options = &yt.SetNodeOptions{}
+	}
+	parsed, err := parseYtsaurusFakePath(path)
+	if err != nil {
+		return err
+	}
+	if parsed.isAttr {
+		node, err := f.resolveNode(parsed)
+		if err != nil {
+			return err
+		}
+		if parsed.attrName == "" {
+			return yterrors.Err("Setting all attributes at once is not supported by the fake")
+		}
+		return f.setAttribute(node, parsed, value)
+	}
+	if len(parsed.nodePath) == 0 {
+		return yterrors.Err("Cannot set the root node")
+	}
+
+	normalized, err := normalizeYtsaurusFakeValue(value)
+	if err != nil {
+		return err
+	}
+	node := f.findNode(parsed.nodePath)
+	if node != nil {
+		switch {
+		case node.typ == yt.NodeDocument:
+			node.value = normalized
+			return nil
+		case node.typ == yt.NodeMap && options.Force:
+			node.children = nil
+			node.typ = yt.NodeDocument
+			node.value = normalized
+			return nil
+		}
+		return yterrors.Err(fmt.Sprintf("Cannot set %s node %s", node.typ, parsed.raw))
+	}
+
+	parent, err := f.getParent(parsed.nodePath, options.Recursive)
+	if err != nil {
+		return err
+	}
+	parent.addChild(&ytsaurusFakeNode{
+		typ:    yt.NodeDocument,
+		name:   parsed.nodePath[len(parsed.nodePath)-1],
+		parent: parent,
+		value:  normalized,
+		attrs:  make(map[string]any),
+	})
+	return nil
+}
+
+// getParent returns parent map node of the path, missing parents are created if recursive is set.
+func (f *YtsaurusFake) getParent(nodePath []string, recursive bool) (*ytsaurusFakeNode, error) {
+	parent := f.root
+	parentPath := nodePath[:len(nodePath)-1]
+	for idx, name := range parentPath {
+		child := parent.children[name]
+		if child == nil {
+			if !recursive {
+				return nil, ytsaurusFakeResolveError("//" + strings.Join(parentPath[:idx+1], "/"))
+			}
+			child = parent.addChild(newYtsaurusFakeMapNode(name, parent))
+		}
+		if child.typ != yt.NodeMap {
+			return nil, yterrors.Err(fmt.Sprintf("Node %s is not a map node", child.path()))
+		}
+		parent = child
+	}
+	return parent, nil
+}
+
+// CreateNode supports only map nodes.
+func (f *YtsaurusFake) CreateNode(
+	ctx context.Context,
+	path ypath.YPath,
+	typ yt.NodeType,
+	options *yt.CreateNodeOptions,
+) (yt.NodeID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if options == nil {
+		options = &yt.CreateNodeOptions{}
+	}
+	if typ != yt.NodeMap {
+		return yt.NodeID{}, yterrors.Err(fmt.Sprintf("Node type %s is not supported by the fake", typ))
+	}
+	parsed, err := parseYtsaurusFakePath(path)
+	if err != nil {
+		return yt.NodeID{}, err
+	}
+	if parsed.isAttr || len(parsed.nodePath) == 0 {
+		return yt.NodeID{}, yterrors.Err(fmt.Sprintf("Cannot create node %s", parsed.raw))
+	}
+	if existing := f.findNode(parsed.nodePath); existing != nil {
+		switch {
+		case options.IgnoreExisting && existing.typ == typ:
+			return yt.NodeID(guid.New()), nil
+		case !options.Force:
+			return yt.NodeID{}, yterrors.Err(
+				yterrors.CodeAlreadyExists,
+				fmt.Sprintf("Node %s already exists", parsed.raw),
+			)
+		}
+		delete(existing.parent.children, existing.name)
+	}
+
+	parent, err := f.getParent(parsed.nodePath, options.Recursive)
+	if err != nil {
+		return yt.NodeID{}, err
+	}
+	node := newYtsaurusFakeMapNode(parsed.nodePath[len(parsed.nodePath)-1], parent)
+	for key, value := range options.Attributes {
+		err = f.setAttribute(node, &ytsaurusFakePath{raw: key, isAttr: true, attrName: key}, value)
+		if err != nil {
+			return yt.NodeID{}, err
+		}
+	}
+	parent.addChild(node)
+	return yt.NodeID(guid.New()), nil
+}
+
+// MoveNode supports only map and document nodes.
+func (f *YtsaurusFake) MoveNode(ctx context.Context, src ypath.YPath, dst ypath.YPath, options *yt.MoveNodeOptions) (yt.NodeID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if options == nil {
+		options = &yt.MoveNodeOptions{}
+	}
+	srcParsed, err := parseYtsaurusFakePath(src)
+	if err != nil {
+		return yt.NodeID{}, err
+	}
+	dstParsed, err := parseYtsaurusFakePath(dst)
+	if err != nil {
+		return yt.NodeID{}, err
+	}
+	if srcParsed.isAttr || dstParsed.isAttr || len(dstParsed.nodePath) == 0 {
+		return yt.NodeID{}, yterrors.Err(fmt.Sprintf("Cannot move %s to %s", srcParsed.raw, dstParsed.raw))
+	}
+	node, err := f.resolveNode(srcParsed)
+	if err != nil {
+		return yt.NodeID{}, err
+	}
+	if node.typ != yt.NodeMap && node.typ != yt.NodeDocument || node.parent == nil {
+		return yt.NodeID{}, yterrors.Err(fmt.Sprintf("Cannot move %s node %s", node.typ, srcParsed.raw))
+	}
+	if strings.HasPrefix(dstParsed.raw+"/", srcParsed.raw+"/") {
+		return yt.NodeID{}, yterrors.Err(fmt.Sprintf("Cannot move %s into itself", srcParsed.raw))
+	}
+	if existing := f.findNode(dstParsed.nodePath); existing != nil {
+		if !options.Force {
+			return yt.NodeID{}, yterrors.Err(
+				yterrors.CodeAlreadyExists,
+				fmt.Sprintf("Node %s already exists", dstParsed.raw),
+			)
+		}
+		delete(existing.parent.children, existing.name)
+	}
+
+	parent, err := f.getParent(dstParsed.nodePath, options.Recursive)
+	if err != nil {
+		return yt.NodeID{}, err
+	}
+	delete(node.parent.children, node.name)
+	node.name = dstParsed.nodePath[len(dstParsed.nodePath)-1]
+	node.parent = parent
+	parent.addChild(node)
+	return yt.NodeID(guid.New()), nil
+}
+
+func (f *YtsaurusFake) MultisetAttributes(
+	ctx context.Context,
+	path ypath.YPath,
+	attributes map[string]any,
+	options *yt.MultisetAttributesOptions,
+) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parsed, err := parseYtsaurusFakePath(path)
+	if err != nil {
+		return err
+	}
+	if !parsed.isAttr || parsed.attrName != "" {
+		return yterrors.Err(fmt.Sprintf("Path %s should end with /@", parsed.raw))
+	}
+	node, err := f.resolveNode(parsed)
+	if err != nil {
+		return err
+	}
+
+	// As in YTsaurus, other attributes are set even if the rename fails, so name goes last.
+	var keys []string
+	for key := range attributes {
+		if key != "name" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if _, ok := attributes["name"]; ok {
+		keys = append(keys, "name")
+	}
+	for _, key := range keys {
+		err = f.setAttribute(node, &ytsaurusFakePath{raw: parsed.raw + key, isAttr: true, attrName: key}, attributes[key])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *YtsaurusFake) ListNode(ctx context.Context, path ypath.YPath, result any, options *yt.ListNodeOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parsed, err := parseYtsaurusFakePath(path)
+	if err != nil {
+		return err
+	}
+	if parsed.isAttr {
+		return yterrors.Err("Listing attributes is not supported by the fake")
+	}
+	node, err := f.resolveNode(parsed)
+	if err != nil {
+		return err
+	}
+	if node.children == nil {
+		return yterrors.Err(fmt.Sprintf("%s node %s can't be listed", node.typ, parsed.raw))
+	}
+
+	var names []string
+	for name := range node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var attributes []string
+	if options != nil {
+		attributes = options.Attributes
+	}
+	if len(attributes) == 0 {
+		return decodeYtsaurusFakeValue(names, result)
+	}
+	items := make([]yson.ValueWithAttrs, 0, len(names))
+	for _, name := range names {
+		attrs := make(map[string]any)
+		for _, attr := range attributes {
+			if value, ok := f.getAttribute(node.children[name], attr); ok {
+				attrs[attr] = value
+			}
+		}
+		items = append(items, yson.ValueWithAttrs{Value: name, Attrs: attrs})
+	}
+	return decodeYtsaurusFakeValue(items, result)
+}
+
+func (f *YtsaurusFake) AddMember(ctx context.Context, group string, member string, options *yt.AddMemberOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	groupNode := f.groupsNode().children[group]
+	if groupNode == nil {
+		return yterrors.Err(yterrors.CodeNoSuchSubject, fmt.Sprintf("No such group %q", group))
+	}
+	if f.findSubject(member) == nil {
+		return yterrors.Err(yterrors.CodeNoSuchSubject, fmt.Sprintf("No such subject %q", member))
+	}
+	for _, existing := range groupNode.members {
+		if existing == member {
+			return yterrors.Err(
+				yterrors.CodeAlreadyPresentInGroup,
+				fmt.Sprintf("Member %q is already present in group %q", member, group),
+			)
+		}
+	}
+	groupNode.members = append(groupNode.members, member)
+	return nil
+}
+
+func (f *YtsaurusFake) RemoveMember(ctx context.Context, group string, member string, options *yt.RemoveMemberOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	groupNode := f.groupsNode().children[group]
+	if groupNode == nil {
+		return yterrors.Err(yterrors.CodeNoSuchSubject, fmt.Sprintf("No such group %q", group))
+	}
+	for idx, existing := range groupNode.members {
+		if existing == member {
+			groupNode.members = append(groupNode.members[:idx], groupNode.members[idx+1:]...)
+			return nil
+		}
+	}
+	return yterrors.Err(fmt.Sprintf("Member %q is not present in group %q", member, group))
+}
+
+func (f *YtsaurusFake) Stop() {}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.ytsaurus.tech/yt/go/ypath"
+	"go.ytsaurus.tech/yt/go/yt"
+	"go.ytsaurus.tech/yt/go/yterrors"
+)
+
+func TestYtsaurusFakeErrorSemantics(t *testing.T) {
+	ctx := context.Background()
+	client := NewYtsaurusFake()
+
+	require.NoError(t, doCreateYtsaurusUser(ctx, client, "oleg", map[string]any{"azure": map[string]any{"id": "1"}}))
+	err := doCreateYtsaurusUser(ctx, client, "oleg", nil)
+	require.True(t, yterrors.ContainsAlreadyExistsError(err))
+
+	var memberOf []string
+	require.NoError(t, client.GetNode(ctx, ypath.Path("//sys/users/oleg/@member_of"), &memberOf, nil))
+	require.Equal(t, []string{"users"}, memberOf)
+
+	require.NoError(t, doCreateYtsaurusGroup(ctx, client, "olegs", nil))
+	require.NoError(t, doAddMemberYtsaurusGroup(ctx, client, "oleg", "olegs"))
+	err = doAddMemberYtsaurusGroup(ctx, client, "oleg", "olegs")
+	require.True(t, yterrors.ContainsErrorCode(err, yterrors.CodeAlreadyPresentInGroup))
+	err = doAddMemberYtsaurusGroup(ctx, client, "nobody", "olegs")
+	require.True(t, yterrors.ContainsErrorCode(err, yterrors.CodeNoSuchSubject))
+
+	// As in YTsaurus, setting the same name fails.
+	err = client.MultisetAttributes(ctx, ypath.Path("//sys/users/oleg/@"), map[string]any{"name": "oleg"}, nil)
+	require.True(t, yterrors.ContainsAlreadyExistsError(err))
+	require.NoError(t, client.MultisetAttributes(ctx, ypath.Path("//sys/users/oleg/@"), map[string]any{"name": "olga"}, nil))
+	var members []string
+	require.NoError(t, client.GetNode(ctx, ypath.Path("//sys/groups/olegs/@members"), &members, nil))
+	require.Equal(t, []string{"olga"}, members)
+
+	err = client.RemoveNode(ctx, ypath.Path("//sys/users/oleg"), nil)
+	require.True(t, yterrors.ContainsResolveError(err))
+	require.NoError(t, client.RemoveNode(ctx, ypath.Path("//sys/users/oleg"), &yt.RemoveNodeOptions{Force: true}))
+	require.Error(t, client.RemoveNode(ctx, ypath.Path("//sys/users/root"), nil))
+
+	require.NoError(t, client.RemoveNode(ctx, ypath.Path("//sys/users/olga"), nil))
+	require.NoError(t, client.GetNode(ctx, ypath.Path("//sys/groups/olegs/@members"), &members, nil))
+	require.Empty(t, members)
+
+	err = client.SetNode(ctx, ypath.Path("//tmp/a/b"), 1, nil)
+	require.True(t, yterrors.ContainsResolveError(err))
+	require.NoError(t, client.SetNode(ctx, ypath.Path("//tmp/a/b"), 1, &yt.SetNodeOptions{Recursive: true}))
+	err = client.RemoveNode(ctx, ypath.Path("//tmp/a"), nil)
+	require.Error(t, err)
+	require.NoError(t, client.RemoveNode(ctx, ypath.Path("//tmp/a"), &yt.RemoveNodeOptions{Recursive: true}))
+}
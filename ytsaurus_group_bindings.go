@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ensureGroupBindable checks that the group bound to the source group is not managed by the app,
+// so bindings never interfere with groups synced from the source.
+func (y *Ytsaurus) ensureGroupBindable(groupname string) error {
+	isManaged, err := y.isGroupManaged(groupname)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to check if group %s is managed", groupname)
+	}
+	if isManaged {
+		return errors.New("Prevented attempt to change membership of managed group via binding " + groupname)
+	}
+	return nil
+}
+
+// AddBoundGroupMember adds the managed user to the unmanaged group bound to the source group.
+func (y *Ytsaurus) AddBoundGroupMember(username, groupname string) error {
+	if y.dryRunMembers {
+		y.logger.Debugw("[DRY-RUN] Going to add bound group member", "username", username, "groupname", groupname)
+		return nil
+	}
+	if err := y.ensureUserManaged(username); err != nil {
+		return err
+	}
+	if err := y.ensureGroupBindable(groupname); err != nil {
+		return err
+	}
+	y.logger.Debugw("Going to add bound group member", "username", username, "groupname", groupname)
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	y.maybePrintExtraLogs(groupname, "add_bound_group_member", "username", username, "groupname", groupname)
+	y.maybePrintExtraLogs(username, "add_bound_group_member", "username", username, "groupname", groupname)
+	return doAddMemberYtsaurusGroup(ctx, y.client, username, groupname)
+}
+
+// RemoveBoundGroupMember removes the member from the unmanaged group bound to the source group.
+// Unmanaged members are passed here only according to the unmanaged members policy.
+func (y *Ytsaurus) RemoveBoundGroupMember(member, groupname string) error {
+	if y.dryRunMembers {
+		y.logger.Debugw("[DRY-RUN] Going to remove bound group member", "member", member, "groupname", groupname)
+		return nil
+	}
+	if err := y.ensureGroupBindable(groupname); err != nil {
+		return err
+	}
+	y.logger.Debugw("Going to remove bound group member", "member", member, "groupname", groupname)
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	y.maybePrintExtraLogs(groupname, "remove_bound_group_member", "member", member, "groupname", groupname)
+	y.maybePrintExtraLogs(member, "remove_bound_group_member", "member", member, "groupname", groupname)
+	return doRemoveMemberYtsaurusGroup(ctx, y.client, member, groupname)
+}
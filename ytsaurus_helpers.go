@@ -11,31 +11,50 @@ import (
 )
 
 // Lower level functions for reusing in tests.
+// They depend only on ytsaurusClient, so they work the same way with HTTP and RPC clients and with the fake.
 
 const (
 	bannedSinceAttributeName = "banned_since"
 	bannedAttributeName      = "banned"
+	banReasonAttributeName   = "ban_reason"
+	banCycleIDAttributeName  = "ban_cycle_id"
+	removeAfterAttributeName = "scheduled_removal_time"
 	membersAttributeName     = "members"
 	nameAttributeName        = "name"
 )
 
-func doGetAllYtsaurusUsers(ctx context.Context, client yt.Client, sourceAttributeName string) ([]YtsaurusUser, error) {
+func doGetAllYtsaurusUsers(
+	ctx context.Context,
+	client ytsaurusClient,
+	sourceAttributeName string,
+	userAttributes UserAttributesConfig,
+) ([]YtsaurusUser, error) {
 	type YtsaurusUserResponse struct {
 		Name  string         `yson:",value"`
 		Attrs map[string]any `yson:",attrs"`
 	}
 
+	attributes := []string{
+		bannedAttributeName,
+		bannedSinceAttributeName,
+		banReasonAttributeName,
+		banCycleIDAttributeName,
+		removeAfterAttributeName,
+		sourceAttributeName,
+	}
+	for _, name := range []string{userAttributes.EmailAttributeName, userAttributes.FullNameAttributeName} {
+		if name != "" {
+			attributes = append(attributes, name)
+		}
+	}
+
 	var response []YtsaurusUserResponse
 	err := client.ListNode(
 		ctx,
 		ypath.Path("//sys/users"),
 		&response,
 		&yt.ListNodeOptions{
-			Attributes: []string{
-				bannedAttributeName,
-				bannedSinceAttributeName,
-				sourceAttributeName,
-			},
+			Attributes: attributes,
 		},
 	)
 	if err != nil {
@@ -49,14 +68,44 @@ func doGetAllYtsaurusUsers(ctx context.Context, client yt.Client, sourceAttribut
 		}
 
 		if ytUser.Attrs != nil {
-			if bannedSinceRaw, ok := ytUser.Attrs[bannedSinceAttributeName]; ok && bannedSinceRaw != "" {
-				user.BannedSince, err = time.Parse(appTimeFormat, bannedSinceRaw.(string))
+			bannedSince, err := getStringAttribute(ytUser.Attrs, bannedSinceAttributeName)
+			if err != nil {
+				return nil, errors.Wrapf(err, "user %s", ytUser.Name)
+			}
+			if bannedSince != "" {
+				user.BannedSince, err = time.Parse(appTimeFormat, bannedSince)
 				if err != nil {
 					return nil, errors.Wrapf(err, "failed to parse @banned_since. %v", ytUser)
 				}
 			}
-			if sourceRaw, ok := ytUser.Attrs[sourceAttributeName]; ok {
-				user.SourceRaw = sourceRaw.(map[string]any)
+			banReason, err := getStringAttribute(ytUser.Attrs, banReasonAttributeName)
+			if err != nil {
+				return nil, errors.Wrapf(err, "user %s", ytUser.Name)
+			}
+			user.BanReason = BanReason(banReason)
+			user.BanCycleID, err = getStringAttribute(ytUser.Attrs, banCycleIDAttributeName)
+			if err != nil {
+				return nil, errors.Wrapf(err, "user %s", ytUser.Name)
+			}
+			removeAfter, err := getStringAttribute(ytUser.Attrs, removeAfterAttributeName)
+			if err != nil {
+				return nil, errors.Wrapf(err, "user %s", ytUser.Name)
+			}
+			if removeAfter != "" {
+				user.RemoveAfter, err = time.Parse(appTimeFormat, removeAfter)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to parse @%s. %v", removeAfterAttributeName, ytUser)
+				}
+			}
+			user.SourceRaw, err = getMapAttribute(ytUser.Attrs, sourceAttributeName)
+			if err != nil {
+				return nil, errors.Wrapf(err, "user %s", ytUser.Name)
+			}
+			if userAttributes.EmailAttributeName != "" {
+				user.Email, _ = ytUser.Attrs[userAttributes.EmailAttributeName].(string)
+			}
+			if userAttributes.FullNameAttributeName != "" {
+				user.FullName, _ = ytUser.Attrs[userAttributes.FullNameAttributeName].(string)
 			}
 		}
 
@@ -65,7 +114,7 @@ func doGetAllYtsaurusUsers(ctx context.Context, client yt.Client, sourceAttribut
 	return users, nil
 }
 
-func doGetAllYtsaurusGroupsWithMembers(ctx context.Context, client yt.Client, sourceAttributeName string) ([]YtsaurusGroupWithMembers, error) {
+func doGetAllYtsaurusGroupsWithMembers(ctx context.Context, client ytsaurusClient, sourceAttributeName string) ([]YtsaurusGroupWithMembers, error) {
 	type YtsaurusGroupReponse struct {
 		Name  string         `yson:",value"`
 		Attrs map[string]any `yson:",attrs"`
@@ -94,14 +143,17 @@ func doGetAllYtsaurusGroupsWithMembers(ctx context.Context, client yt.Client, so
 		group := YtsaurusGroup{Name: ytGroup.Name}
 
 		if ytGroup.Attrs != nil {
-			if membersRaw, ok := ytGroup.Attrs[membersAttributeName]; ok {
-				for _, m := range membersRaw.([]interface{}) {
-					members.Add(m.(string))
-				}
+			groupMembers, err := getStringListAttribute(ytGroup.Attrs, membersAttributeName)
+			if err != nil {
+				return nil, errors.Wrapf(err, "group %s", ytGroup.Name)
+			}
+			for _, member := range groupMembers {
+				members.Add(member)
 			}
 
-			if sourceRaw, ok := ytGroup.Attrs[sourceAttributeName]; ok {
-				group.SourceRaw = sourceRaw.(map[string]any)
+			group.SourceRaw, err = getMapAttribute(ytGroup.Attrs, sourceAttributeName)
+			if err != nil {
+				return nil, errors.Wrapf(err, "group %s", ytGroup.Name)
 			}
 		}
 
@@ -113,7 +165,95 @@ func doGetAllYtsaurusGroupsWithMembers(ctx context.Context, client yt.Client, so
 	return groups, nil
 }
 
-func doCreateYtsaurusUser(ctx context.Context, client yt.Client, username string, attrs map[string]any) error {
+// doGetAllYtsaurusObjectsAttributes lists nodes under the path (e.g. //sys/users) and returns requested attributes
+// of each node by its name. Attributes missing for the node are not presented in its map.
+func doGetAllYtsaurusObjectsAttributes(ctx context.Context, client ytsaurusClient, path ypath.Path, attributes []string) (map[string]map[string]any, error) {
+	type YtsaurusObjectResponse struct {
+		Name  string         `yson:",value"`
+		Attrs map[string]any `yson:",attrs"`
+	}
+
+	var response []YtsaurusObjectResponse
+	err := client.ListNode(
+		ctx,
+		path,
+		&response,
+		&yt.ListNodeOptions{
+			Attributes: attributes,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[string]map[string]any)
+	for _, object := range response {
+		attrs := object.Attrs
+		if attrs == nil {
+			attrs = make(map[string]any)
+		}
+		objects[object.Name] = attrs
+	}
+	return objects, nil
+}
+
+// getStringAttribute returns the attribute decoded from YSON, empty string is returned if it is missing.
+// Attribute types are checked, so unexpected values are reported as errors instead of panics.
+func getStringAttribute(attrs map[string]any, name string) (string, error) {
+	raw, ok := attrs[name]
+	if !ok || raw == nil {
+		return "", nil
+	}
+	value, ok := raw.(string)
+	if !ok {
+		return "", errors.Errorf("unexpected type %T of @%s", raw, name)
+	}
+	return value, nil
+}
+
+// getMapAttribute returns the attribute decoded from YSON, nil is returned if it is missing.
+func getMapAttribute(attrs map[string]any, name string) (map[string]any, error) {
+	raw, ok := attrs[name]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	value, ok := raw.(map[string]any)
+	if !ok {
+		return nil, errors.Errorf("unexpected type %T of @%s", raw, name)
+	}
+	return value, nil
+}
+
+// getStringListAttribute returns the attribute decoded from YSON, nil is returned if it is missing.
+func getStringListAttribute(attrs map[string]any, name string) ([]string, error) {
+	raw, ok := attrs[name]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, errors.Errorf("unexpected type %T of @%s", raw, name)
+	}
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		value, ok := item.(string)
+		if !ok {
+			return nil, errors.Errorf("unexpected type %T of @%s item", item, name)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func doRemoveYtsaurusAttribute(ctx context.Context, client ytsaurusClient, path ypath.Path, attrName string) error {
+	return client.RemoveNode(
+		ctx,
+		path.Attr(attrName),
+		nil,
+	)
+}
+
+func doCreateYtsaurusUser(ctx context.Context, client ytsaurusClient, username string, attrs map[string]any) error {
 	if attrs == nil {
 		attrs = make(map[string]any)
 	}
@@ -126,7 +266,7 @@ func doCreateYtsaurusUser(ctx context.Context, client yt.Client, username string
 	return err
 }
 
-func doCreateYtsaurusGroup(ctx context.Context, client yt.Client, name string, attrs map[string]any) error {
+func doCreateYtsaurusGroup(ctx context.Context, client ytsaurusClient, name string, attrs map[string]any) error {
 	if attrs == nil {
 		attrs = make(map[string]any)
 	}
@@ -139,7 +279,7 @@ func doCreateYtsaurusGroup(ctx context.Context, client yt.Client, name string, a
 	return err
 }
 
-func doAddMemberYtsaurusGroup(ctx context.Context, client yt.Client, username, groupname string) error {
+func doAddMemberYtsaurusGroup(ctx context.Context, client ytsaurusClient, username, groupname string) error {
 	return client.AddMember(
 		ctx,
 		groupname,
@@ -148,7 +288,7 @@ func doAddMemberYtsaurusGroup(ctx context.Context, client yt.Client, username, g
 	)
 }
 
-func doRemoveMemberYtsaurusGroup(ctx context.Context, client yt.Client, username, groupname string) error {
+func doRemoveMemberYtsaurusGroup(ctx context.Context, client ytsaurusClient, username, groupname string) error {
 	return client.RemoveMember(
 		ctx,
 		groupname,
@@ -157,13 +297,28 @@ func doRemoveMemberYtsaurusGroup(ctx context.Context, client yt.Client, username
 	)
 }
 
-func buildUserAttributes(user YtsaurusUser, sourceAttributeName string) map[string]any {
-	return map[string]any{
+func buildUserAttributes(user YtsaurusUser, sourceAttributeName string, userAttributes UserAttributesConfig) map[string]any {
+	attrs := map[string]any{
 		nameAttributeName:        user.Username,
 		bannedSinceAttributeName: user.BannedSinceString(),
 		bannedAttributeName:      user.IsBanned(),
+		banReasonAttributeName:   string(user.BanReason),
+		banCycleIDAttributeName:  user.BanCycleID,
+		removeAfterAttributeName: user.RemoveAfterString(),
 		sourceAttributeName:      user.SourceRaw,
 	}
+	addUserProfileAttributes(attrs, user, userAttributes)
+	return attrs
+}
+
+// addUserProfileAttributes adds configured well-known attributes.
+func addUserProfileAttributes(attrs map[string]any, user YtsaurusUser, userAttributes UserAttributesConfig) {
+	if userAttributes.EmailAttributeName != "" {
+		attrs[userAttributes.EmailAttributeName] = user.Email
+	}
+	if userAttributes.FullNameAttributeName != "" {
+		attrs[userAttributes.FullNameAttributeName] = user.FullName
+	}
 }
 
 func buildGroupAttributes(group YtsaurusGroup, sourceAttributeName string) map[string]any {
@@ -174,7 +329,7 @@ func buildGroupAttributes(group YtsaurusGroup, sourceAttributeName string) map[s
 }
 
 // nolint: unused
-func doSetAzureAttributeForYtsaurusUser(ctx context.Context, client yt.Client, username string, attrName string, attrValue any) error {
+func doSetAzureAttributeForYtsaurusUser(ctx context.Context, client ytsaurusClient, username string, attrName string, attrValue any) error {
 	return client.SetNode(
 		ctx,
 		ypath.Path("//sys/users/"+username+"/@"+attrName),
@@ -183,7 +338,7 @@ func doSetAzureAttributeForYtsaurusUser(ctx context.Context, client yt.Client, u
 	)
 }
 
-func doSetAttributesForYtsaurusUser(ctx context.Context, client yt.Client, username string, attrs map[string]any) error {
+func doSetAttributesForYtsaurusUser(ctx context.Context, client ytsaurusClient, username string, attrs map[string]any) error {
 	attrsCopy := make(map[string]any)
 	for key, value := range attrs {
 		if key == nameAttributeName && value == username {
@@ -206,7 +361,7 @@ func doSetAttributesForYtsaurusUser(ctx context.Context, client yt.Client, usern
 // nolint: unused
 func doSetAzureAttributeForYtsaurusGroup(
 	ctx context.Context,
-	client yt.Client,
+	client ytsaurusClient,
 	groupname string,
 	attrName string,
 	attrValue map[string]string,
@@ -219,7 +374,7 @@ func doSetAzureAttributeForYtsaurusGroup(
 	)
 }
 
-func doSetAttributesForYtsaurusGroup(ctx context.Context, client yt.Client, groupname string, attrs map[string]any) error {
+func doSetAttributesForYtsaurusGroup(ctx context.Context, client ytsaurusClient, groupname string, attrs map[string]any) error {
 	return client.MultisetAttributes(
 		ctx,
 		ypath.Path("//sys/groups/"+groupname+"/@"),
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"go.ytsaurus.tech/yt/go/ypath"
+	"go.ytsaurus.tech/yt/go/yt"
+)
+
+// HomeDirectoryRemovalPolicy defines what happens with the home directory when its user is removed.
+type HomeDirectoryRemovalPolicy string
+
+const (
+	// HomeDirectoryRemovalPolicyKeep leaves the home directory as is (default).
+	HomeDirectoryRemovalPolicyKeep HomeDirectoryRemovalPolicy = "keep"
+	// HomeDirectoryRemovalPolicyArchive moves the home directory to the archive path.
+	HomeDirectoryRemovalPolicyArchive HomeDirectoryRemovalPolicy = "archive"
+
+	homeDirectoryUsernamePlaceholder = "{username}"
+	homeDirectoryCycleIDPlaceholder  = "{cycle_id}"
+)
+
+var defaultHomeDirectoryPermissions = []string{
+	string(yt.PermissionRead),
+	string(yt.PermissionWrite),
+	string(yt.PermissionRemove),
+	string(yt.PermissionAdminister),
+}
+
+func validateHomeDirectoriesConfig(cfg *HomeDirectoriesConfig) error {
+	if cfg.PathTemplate == "" {
+		return nil
+	}
+	if !strings.Contains(cfg.PathTemplate, homeDirectoryUsernamePlaceholder) {
+		return errors.Errorf("path template %q doesn't contain %s", cfg.PathTemplate, homeDirectoryUsernamePlaceholder)
+	}
+	switch cfg.OnUserRemoval {
+	case "", HomeDirectoryRemovalPolicyKeep:
+		return nil
+	case HomeDirectoryRemovalPolicyArchive:
+		if !strings.Contains(cfg.ArchivePathTemplate, homeDirectoryUsernamePlaceholder) {
+			return errors.Errorf(
+				"archive path template %q doesn't contain %s",
+				cfg.ArchivePathTemplate,
+				homeDirectoryUsernamePlaceholder,
+			)
+		}
+		return nil
+	}
+	return errors.Errorf("unknown home directory removal policy %q", cfg.OnUserRemoval)
+}
+
+func (y *Ytsaurus) isHomeDirectoriesEnabled() bool {
+	return y.homeDirectories.PathTemplate != ""
+}
+
+func (y *Ytsaurus) homeDirectoryPath(username string) ypath.Path {
+	return ypath.Path(strings.ReplaceAll(y.homeDirectories.PathTemplate, homeDirectoryUsernamePlaceholder, username))
+}
+
+func (y *Ytsaurus) homeDirectoryArchivePath(username, cycleID string) ypath.Path {
+	replacer := strings.NewReplacer(
+		homeDirectoryUsernamePlaceholder, username,
+		homeDirectoryCycleIDPlaceholder, cycleID,
+	)
+	return ypath.Path(replacer.Replace(y.homeDirectories.ArchivePathTemplate))
+}
+
+func (y *Ytsaurus) homeDirectoryACL(username string) []yt.ACE {
+	permissions := make([]yt.Permission, 0, len(y.homeDirectories.Permissions))
+	for _, permission := range y.homeDirectories.Permissions {
+		permissions = append(permissions, yt.Permission(permission))
+	}
+	return []yt.ACE{{
+		Action:      yt.ActionAllow,
+		Subjects:    []string{username},
+		Permissions: permissions,
+	}}
+}
+
+// CreateHomeDirectory creates the home directory with ACL granting configured permissions to the user.
+// Existing directory (e.g. kept after removal of the previous user with the same name) is left as is
+// and reported as an error, so the new user doesn't take over the data and ACL of the previous one.
+func (y *Ytsaurus) CreateHomeDirectory(username string) error {
+	if !y.isHomeDirectoriesEnabled() {
+		return nil
+	}
+	path := y.homeDirectoryPath(username)
+	logger := y.logger.With("username", username, "path", path)
+	acl := y.homeDirectoryACL(username)
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	exists, err := y.client.NodeExists(ctx, path, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to check home directory")
+	}
+	if exists {
+		return errors.Errorf("home directory %s already exists, it may belong to the previous user with the same name", path)
+	}
+
+	if y.dryRunUsers {
+		logger.Debugw("[DRY-RUN] Going to create home directory", "acl", acl)
+		return nil
+	}
+	logger.Debugw("Going to create home directory", "acl", acl)
+	y.maybePrintExtraLogs(username, "create_home_directory", "path", path, "acl", acl)
+	_, err = y.client.CreateNode(
+		ctx,
+		path,
+		yt.NodeMap,
+		&yt.CreateNodeOptions{
+			Recursive:  true,
+			Attributes: map[string]any{"acl": acl},
+		},
+	)
+	return err
+}
+
+// ArchiveHomeDirectory moves the home directory of the removed user to the archive path
+// if archive policy is configured. Missing home directory is skipped.
+func (y *Ytsaurus) ArchiveHomeDirectory(username, cycleID string) error {
+	if !y.isHomeDirectoriesEnabled() || y.homeDirectories.OnUserRemoval != HomeDirectoryRemovalPolicyArchive {
+		return nil
+	}
+	path := y.homeDirectoryPath(username)
+	archivePath := y.homeDirectoryArchivePath(username, cycleID)
+	logger := y.logger.With("username", username, "path", path, "archive_path", archivePath)
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	exists, err := y.client.NodeExists(ctx, path, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to check home directory")
+	}
+	if !exists {
+		logger.Debugw("Home directory doesn't exist, nothing to archive")
+		return nil
+	}
+
+	if y.dryRunUsers {
+		logger.Debugw("[DRY-RUN] Going to archive home directory")
+		return nil
+	}
+	logger.Debugw("Going to archive home directory")
+	y.maybePrintExtraLogs(username, "archive_home_directory", "path", path, "archive_path", archivePath)
+	_, err = y.client.MoveNode(ctx, path, archivePath, &yt.MoveNodeOptions{Recursive: true})
+	return err
+}
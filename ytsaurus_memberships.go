@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"go.ytsaurus.tech/yt/go/ypath"
+	"go.ytsaurus.tech/yt/go/yt"
+)
+
+const memberOfAttributeName = "member_of"
+
+// membershipsRecord is stored in the memberships store for every banned user.
+type membershipsRecord struct {
+	Username string   `yson:"username"`
+	MemberOf []string `yson:"member_of"`
+}
+
+func (y *Ytsaurus) isMembershipsStoreEnabled() bool {
+	return y.membershipsStorePath != ""
+}
+
+func (y *Ytsaurus) membershipsRecordPath(sourceID ObjectID) ypath.Path {
+	return ypath.Path(y.membershipsStorePath).Child(sourceID)
+}
+
+// RecordMemberships saves groups the user is currently member of, so they can be restored
+// if the user is removed and then returns to the source.
+func (y *Ytsaurus) RecordMemberships(sourceID ObjectID, username string) error {
+	if !y.isMembershipsStoreEnabled() {
+		return nil
+	}
+	logger := y.logger.With("username", username, "source_id", sourceID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	var memberOf []string
+	err := y.client.GetNode(
+		ctx,
+		ypath.Path("//sys/users/"+username).Attr(memberOfAttributeName),
+		&memberOf,
+		nil,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to get user memberships")
+	}
+	record := membershipsRecord{Username: username, MemberOf: memberOf}
+
+	if y.dryRunUsers {
+		logger.Debugw("[DRY-RUN] Going to record memberships", "record", record)
+		return nil
+	}
+	logger.Debugw("Going to record memberships", "record", record)
+	y.maybePrintExtraLogs(username, "record_memberships", "record", record)
+	return y.client.SetNode(
+		ctx,
+		y.membershipsRecordPath(sourceID),
+		record,
+		&yt.SetNodeOptions{Recursive: true, Force: true},
+	)
+}
+
+// RestoreMemberships adds the user to the recorded manually managed groups, which still exist.
+// Managed groups are skipped, since their members are synced from the source.
+// The record is removed after restoration.
+func (y *Ytsaurus) RestoreMemberships(sourceID ObjectID, username string) (restored int, err error) {
+	if !y.isMembershipsStoreEnabled() {
+		return 0, nil
+	}
+	logger := y.logger.With("username", username, "source_id", sourceID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	recordPath := y.membershipsRecordPath(sourceID)
+	exists, err := y.client.NodeExists(ctx, recordPath, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to check memberships record")
+	}
+	if !exists {
+		return 0, nil
+	}
+	var record membershipsRecord
+	err = y.client.GetNode(ctx, recordPath, &record, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get memberships record")
+	}
+
+	for _, groupname := range record.MemberOf {
+		if builtinSubjectNames.Contains(groupname) {
+			continue
+		}
+		groupExists, err := y.client.NodeExists(ctx, ypath.Path("//sys/groups/"+groupname), nil)
+		if err != nil {
+			return restored, errors.Wrapf(err, "failed to check if group %s exists", groupname)
+		}
+		if !groupExists {
+			logger.Debugw("Recorded group doesn't exist anymore", "groupname", groupname)
+			continue
+		}
+		isManaged, err := y.isGroupManaged(groupname)
+		if err != nil {
+			return restored, errors.Wrapf(err, "failed to check if group %s is managed", groupname)
+		}
+		if isManaged {
+			continue
+		}
+		if y.dryRunMembers {
+			logger.Debugw("[DRY-RUN] Going to restore member", "groupname", groupname)
+			restored++
+			continue
+		}
+		logger.Debugw("Going to restore member", "groupname", groupname)
+		y.maybePrintExtraLogs(username, "restore_member", "username", username, "groupname", groupname)
+		err = doAddMemberYtsaurusGroup(ctx, y.client, username, groupname)
+		if err != nil {
+			logger.Errorw("failed to restore member", zap.Error(err), "groupname", groupname)
+			continue
+		}
+		restored++
+	}
+
+	if y.dryRunMembers {
+		return restored, nil
+	}
+	return restored, y.client.RemoveNode(ctx, recordPath, nil)
+}
+
+// ForgetMemberships removes memberships record for the user, which was reactivated before removal
+// (so it still has all its memberships).
+func (y *Ytsaurus) ForgetMemberships(sourceID ObjectID) error {
+	if !y.isMembershipsStoreEnabled() {
+		return nil
+	}
+	if y.dryRunUsers {
+		y.logger.Debugw("[DRY-RUN] Going to forget memberships", "source_id", sourceID)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	return y.client.RemoveNode(ctx, y.membershipsRecordPath(sourceID), &yt.RemoveNodeOptions{Force: true})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"go.ytsaurus.tech/yt/go/ypath"
+)
+
+// MigrateSourceAttribute copies legacy source attribute of users and groups to the configured
+// source attribute and optionally removes the legacy one.
+// Objects which already have the new attribute are never overwritten.
+func (y *Ytsaurus) MigrateSourceAttribute(cfg SourceAttributeMigrationConfig) error {
+	if cfg.LegacyAttributeName == "" {
+		return errors.New("legacy attribute name for the source attribute migration should be specified")
+	}
+	if cfg.LegacyAttributeName == y.sourceAttributeName {
+		return errors.Errorf("legacy attribute name is the same as the source attribute name: %s", y.sourceAttributeName)
+	}
+
+	logger := y.logger.With(
+		"legacy_attribute", cfg.LegacyAttributeName,
+		"attribute", y.sourceAttributeName,
+	)
+	logger.Info("Start source attribute migration")
+
+	changed := 0
+	var errCount int
+	for _, root := range []ypath.Path{"//sys/users", "//sys/groups"} {
+		ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+		objects, err := doGetAllYtsaurusObjectsAttributes(
+			ctx,
+			y.client,
+			root,
+			[]string{cfg.LegacyAttributeName, y.sourceAttributeName},
+		)
+		cancel()
+		if err != nil {
+			return errors.Wrapf(err, "failed to list %s", root)
+		}
+
+		for name, attrs := range objects {
+			legacyValue, hasLegacy := attrs[cfg.LegacyAttributeName]
+			if !hasLegacy {
+				continue
+			}
+			_, hasNew := attrs[y.sourceAttributeName]
+			needCopy := !hasNew
+			needRemove := cfg.RemoveLegacyAttribute
+			if !needCopy && !needRemove {
+				continue
+			}
+			if cfg.Limit > 0 && changed >= cfg.Limit {
+				logger.Warnw("Migration limit reached, the rest of objects will be migrated in the next launch",
+					"limit", cfg.Limit,
+				)
+				logger.Infow("Finish source attribute migration", "migrated", changed-errCount, "errors", errCount)
+				return nil
+			}
+			changed++
+
+			path := root.Child(name)
+			if !cfg.ApplyChanges {
+				logger.Infow("[DRY-RUN] Going to migrate source attribute",
+					"path", path,
+					"copy", needCopy,
+					"remove_legacy", needRemove,
+					"value", legacyValue,
+				)
+				continue
+			}
+			logger.Infow("Going to migrate source attribute",
+				"path", path,
+				"copy", needCopy,
+				"remove_legacy", needRemove,
+				"value", legacyValue,
+			)
+			y.maybePrintExtraLogs(name, "migrate_source_attribute", "path", path, "value", legacyValue)
+			err = y.migrateSourceAttribute(path, cfg.LegacyAttributeName, legacyValue, needCopy, needRemove)
+			if err != nil {
+				errCount++
+				logger.Errorw("failed to migrate source attribute", zap.Error(err), "path", path)
+			}
+		}
+	}
+	logger.Infow("Finish source attribute migration", "migrated", changed-errCount, "errors", errCount)
+	return nil
+}
+
+func (y *Ytsaurus) migrateSourceAttribute(path ypath.Path, legacyName string, value any, needCopy, needRemove bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	if needCopy {
+		err := y.client.SetNode(ctx, path.Attr(y.sourceAttributeName), value, nil)
+		if err != nil {
+			return errors.Wrap(err, "failed to copy legacy attribute")
+		}
+	}
+	if needRemove {
+		err := doRemoveYtsaurusAttribute(ctx, y.client, path, legacyName)
+		if err != nil {
+			return errors.Wrap(err, "failed to remove legacy attribute")
+		}
+	}
+	return nil
+}
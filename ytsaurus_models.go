@@ -4,11 +4,31 @@ import (
 	"time"
 )
 
+// BanReason explains why the user was banned.
+type BanReason string
+
+const (
+	BanReasonMissingFromSource BanReason = "missing_from_source"
+	BanReasonAccountDisabled   BanReason = "account_disabled"
+	BanReasonExcludedByFilter  BanReason = "excluded_by_filter"
+	BanReasonManual            BanReason = "manual"
+)
+
 type YtsaurusUser struct {
 	// Username is a unique @name attribute of a user.
 	Username    string
 	SourceRaw   map[string]any
 	BannedSince time.Time
+	// BanReason is empty for users banned before reasons were recorded.
+	BanReason BanReason
+	// BanCycleID is an ID of the sync cycle in which the user was banned.
+	BanCycleID string
+	// RemoveAfter is a time after which the banned user is going to be removed.
+	// It is zero if removal is not scheduled.
+	RemoveAfter time.Time
+	// Email and FullName are values of the well-known attributes, they are empty if attributes are not configured.
+	Email    string
+	FullName string
 }
 
 // IsManuallyManaged true if user doesn't have @azure attribute (system or manually created user).
@@ -21,10 +41,20 @@ func (u YtsaurusUser) IsBanned() bool {
 }
 
 func (u YtsaurusUser) BannedSinceString() string {
-	if u.BannedSince.IsZero() {
-		return ""
-	}
-	return u.BannedSince.Format(appTimeFormat)
+	return formatAppTime(u.BannedSince)
+}
+
+func (u YtsaurusUser) RemoveAfterString() string {
+	return formatAppTime(u.RemoveAfter)
+}
+
+// WithBanOf returns copy of the user with ban fields taken from the other user.
+func (u YtsaurusUser) WithBanOf(other YtsaurusUser) YtsaurusUser {
+	u.BannedSince = other.BannedSince
+	u.BanReason = other.BanReason
+	u.BanCycleID = other.BanCycleID
+	u.RemoveAfter = other.RemoveAfter
+	return u
 }
 
 type YtsaurusGroup struct {
@@ -48,6 +78,22 @@ func NewEmptyYtsaurusGroupWithMembers(group YtsaurusGroup) YtsaurusGroupWithMemb
 	return YtsaurusGroupWithMembers{YtsaurusGroup: group, Members: NewStringSet()}
 }
 
+// YtsaurusGroupAccount is an account provisioned for a managed group, it has the same name as the group.
+type YtsaurusGroupAccount struct {
+	Name string
+	// SourceRaw is a copy of the group source attribute, it binds the account to the group.
+	SourceRaw map[string]any
+}
+
+// YtsaurusGroupPool is a scheduler pool provisioned for a managed group.
+type YtsaurusGroupPool struct {
+	Name string
+	// Groupname is a name of the group, which is granted `use` permission on the pool.
+	Groupname string
+	// SourceRaw is a copy of the group source attribute, it binds the pool to the group.
+	SourceRaw map[string]any
+}
+
 type YtsaurusMembership struct {
 	GroupName string
 	Username  string
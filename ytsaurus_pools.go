@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"go.ytsaurus.tech/yt/go/ypath"
+	"go.ytsaurus.tech/yt/go/yt"
+)
+
+// GroupPoolRemovalPolicy defines what happens with the group pool when its group is removed.
+type GroupPoolRemovalPolicy string
+
+const (
+	// GroupPoolRemovalPolicyDetach removes the source attribute from the pool, so it is not managed anymore (default).
+	GroupPoolRemovalPolicyDetach GroupPoolRemovalPolicy = "detach"
+	// GroupPoolRemovalPolicyRemove removes the pool.
+	GroupPoolRemovalPolicyRemove GroupPoolRemovalPolicy = "remove"
+
+	defaultPoolTree        = "default"
+	poolTreesPath          = "//sys/pool_trees"
+	poolTreeAttributeName  = "pool_tree"
+	poolNameAllowedSymbols = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"
+	poolPathSeparator      = "/"
+)
+
+func validateGroupPoolsConfig(cfg *GroupPoolsConfig) error {
+	switch cfg.OnGroupRemoval {
+	case "", GroupPoolRemovalPolicyDetach, GroupPoolRemovalPolicyRemove:
+		return nil
+	}
+	return errors.Errorf("unknown group pool removal policy %q", cfg.OnGroupRemoval)
+}
+
+func (y *Ytsaurus) IsGroupPoolsEnabled() bool {
+	return y.groupPools.Enabled
+}
+
+func (y *Ytsaurus) IsGroupPoolRemovalEnabled() bool {
+	return y.groupPools.OnGroupRemoval == GroupPoolRemovalPolicyRemove
+}
+
+// GroupPoolName builds the pool name from the group name, empty string is returned if the result is not a valid pool name.
+func (y *Ytsaurus) GroupPoolName(groupname string) string {
+	name := groupname
+	for _, replace := range y.groupPools.NameReplacements {
+		name = strings.Replace(name, replace.From, replace.To, -1)
+	}
+	if name == "" || strings.Trim(name, poolNameAllowedSymbols) != "" {
+		return ""
+	}
+	return name
+}
+
+// groupPoolsParentPath is a path of the pool tree or the parent pool, where group pools are created.
+func (y *Ytsaurus) groupPoolsParentPath() ypath.Path {
+	path := ypath.Path(poolTreesPath).Child(y.groupPools.PoolTree)
+	for _, pool := range strings.Split(y.groupPools.ParentPoolPath, poolPathSeparator) {
+		if pool != "" {
+			path = path.Child(pool)
+		}
+	}
+	return path
+}
+
+// groupPoolsParentName is a name of the parent pool, it is empty for the pools in the root of the pool tree.
+func (y *Ytsaurus) groupPoolsParentName() string {
+	pools := strings.Split(strings.Trim(y.groupPools.ParentPoolPath, poolPathSeparator), poolPathSeparator)
+	return pools[len(pools)-1]
+}
+
+// GetGroupPools returns pools created for managed groups (having the source attribute).
+// Groupname is not filled, since it is not needed for the diff.
+func (y *Ytsaurus) GetGroupPools() ([]YtsaurusGroupPool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	objects, err := doGetAllYtsaurusObjectsAttributes(ctx, y.client, y.groupPoolsParentPath(), []string{y.sourceAttributeName})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get ytsaurus pools")
+	}
+	var pools []YtsaurusGroupPool
+	for name, attrs := range objects {
+		sourceRaw, ok := attrs[y.sourceAttributeName].(map[string]any)
+		if !ok {
+			continue
+		}
+		pool := YtsaurusGroupPool{Name: name, SourceRaw: sourceRaw}
+		y.maybePrintExtraLogs(name, "get_group_pool", "pool", pool)
+		pools = append(pools, pool)
+	}
+	y.logger.Infow("Fetched all pools from YTsaurus",
+		"pool_tree", y.groupPools.PoolTree,
+		"parent_pool_path", y.groupPools.ParentPoolPath,
+		"total", len(objects),
+		"managed", len(pools),
+	)
+	return pools, nil
+}
+
+// CreateGroupPool creates the pool with default attributes and grants `use` to the group.
+func (y *Ytsaurus) CreateGroupPool(pool YtsaurusGroupPool) error {
+	logger := y.logger.With("pool", pool.Name, "pool_tree", y.groupPools.PoolTree)
+	attrs := make(map[string]any)
+	for key, value := range y.groupPools.Attributes {
+		attrs[key] = value
+	}
+	attrs[nameAttributeName] = pool.Name
+	attrs[poolTreeAttributeName] = y.groupPools.PoolTree
+	attrs[y.sourceAttributeName] = pool.SourceRaw
+	attrs[aclAttributeName] = []yt.ACE{{
+		Action:      yt.ActionAllow,
+		Subjects:    []string{pool.Groupname},
+		Permissions: []yt.Permission{yt.PermissionUse},
+	}}
+	if parentName := y.groupPoolsParentName(); parentName != "" {
+		attrs[parentNameAttributeName] = parentName
+	}
+
+	if y.dryRunGroups {
+		logger.Debugw("[DRY-RUN] Going to create group pool", "attributes", attrs)
+		return nil
+	}
+	logger.Debugw("Going to create group pool", "attributes", attrs)
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	y.maybePrintExtraLogs(pool.Name, "create_group_pool", "attributes", attrs)
+	_, err := y.client.CreateObject(ctx, yt.NodeSchedulerPool, &yt.CreateObjectOptions{Attributes: attrs})
+	return err
+}
+
+// UpdateGroupPool renames the pool after its group and updates its source attribute.
+// Other attributes are not touched. The name in @acl follows the group rename in YTsaurus.
+func (y *Ytsaurus) UpdateGroupPool(name string, pool YtsaurusGroupPool) error {
+	logger := y.logger.With("pool", name, "new_name", pool.Name, "pool_tree", y.groupPools.PoolTree)
+	if y.dryRunGroups {
+		logger.Debugw("[DRY-RUN] Going to update group pool")
+		return nil
+	}
+	logger.Debugw("Going to update group pool")
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	attrs := map[string]any{y.sourceAttributeName: pool.SourceRaw}
+	if pool.Name != name {
+		attrs[nameAttributeName] = pool.Name
+	}
+	y.maybePrintExtraLogs(name, "update_group_pool", "pool", pool)
+	return y.client.MultisetAttributes(ctx, y.groupPoolsParentPath().Child(name).Attrs(), attrs, nil)
+}
+
+// RemoveGroupPool removes the pool or detaches it according to the policy.
+func (y *Ytsaurus) RemoveGroupPool(name string) error {
+	logger := y.logger.With("pool", name, "pool_tree", y.groupPools.PoolTree, "policy", y.groupPools.OnGroupRemoval)
+	if y.dryRunGroups {
+		logger.Debugw("[DRY-RUN] Going to remove group pool")
+		return nil
+	}
+	logger.Debugw("Going to remove group pool")
+
+	ctx, cancel := context.WithTimeout(context.Background(), y.timeout)
+	defer cancel()
+
+	y.maybePrintExtraLogs(name, "remove_group_pool", "pool", name)
+	path := y.groupPoolsParentPath().Child(name)
+	if !y.IsGroupPoolRemovalEnabled() {
+		return doRemoveYtsaurusAttribute(ctx, y.client, path, y.sourceAttributeName)
+	}
+	return y.client.RemoveNode(ctx, path, nil)
+}
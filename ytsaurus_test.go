@@ -7,29 +7,44 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
 	"k8s.io/utils/clock"
 
 	"go.ytsaurus.tech/yt/go/ypath"
+	"go.ytsaurus.tech/yt/go/yt"
 )
 
-func getYtsaurus(t *testing.T, ytLocal *YtsaurusLocal) *Ytsaurus {
+// ytLocalContainerEnvVar enables tests against YTsaurus local container instead of the in-memory fake.
+const ytLocalContainerEnvVar = "YT_LOCAL_CONTAINER"
+
+// getTestYtsaurusClient returns in-memory YTsaurus fake or, if YT_LOCAL_CONTAINER env var is set,
+// a client of the YTsaurus local container (requires Docker, the test is skipped if it is unavailable).
+func getTestYtsaurusClient(t *testing.T) ytsaurusClient {
+	if os.Getenv(ytLocalContainerEnvVar) == "" {
+		return NewYtsaurusFake()
+	}
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ytLocal := NewYtsaurusLocal()
 	require.NoError(t, ytLocal.Start())
+	t.Cleanup(func() { require.NoError(t, ytLocal.Stop()) })
 
-	require.NoError(t, os.Setenv("YT_TOKEN", ytDevToken))
-	yt, err := NewYtsaurus(
+	client, err := ytLocal.GetClient()
+	require.NoError(t, err)
+	return client
+}
+
+func getYtsaurus(t *testing.T, client ytsaurusClient) *Ytsaurus {
+	return newYtsaurusWithClient(
 		&YtsaurusConfig{
-			Proxy:               ytLocal.GetProxy(),
 			Timeout:             10 * time.Minute,
-			LogLevel:            "DEBUG",
 			ApplyUserChanges:    true,
 			ApplyGroupChanges:   true,
 			ApplyMemberChanges:  true,
 			SourceAttributeName: "azure",
-		}, getDevelopmentLogger(),
+		}, client,
+		getDevelopmentLogger(),
 		clock.RealClock{},
 	)
-	require.NoError(t, err)
-	return yt
 }
 
 // TestUpdateUserFirstName is a case for the  specific bug.
@@ -38,9 +53,8 @@ func getYtsaurus(t *testing.T, ytLocal *YtsaurusLocal) *Ytsaurus {
 // Since fields are updated this bug doesn't have consequences, though it is nice not to have
 // scary errors in logs.
 func TestUpdateUserFirstName(t *testing.T) {
-	ytLocal := NewYtsaurusLocal()
-	defer func() { require.NoError(t, ytLocal.Stop()) }()
-	yt := getYtsaurus(t, ytLocal)
+	ytClient := getTestYtsaurusClient(t)
+	yt := getYtsaurus(t, ytClient)
 
 	const azureID = "fake-az-id-old"
 
@@ -61,9 +75,6 @@ func TestUpdateUserFirstName(t *testing.T) {
 
 	updErr := yt.UpdateUser(managedOleg.Username, managedOleg)
 
-	ytClient, err := ytLocal.GetClient()
-	require.NoError(t, err)
-
 	var updatedName string
 	err = ytClient.GetNode(
 		context.Background(),
@@ -78,9 +89,8 @@ func TestUpdateUserFirstName(t *testing.T) {
 }
 
 func TestGroups(t *testing.T) {
-	ytLocal := NewYtsaurusLocal()
-	defer func() { require.NoError(t, ytLocal.Stop()) }()
-	yt := getYtsaurus(t, ytLocal)
+	ytClient := getTestYtsaurusClient(t)
+	yt := getYtsaurus(t, ytClient)
 
 	groupsInitial, err := yt.GetGroupsWithMembers()
 	require.NoError(t, err)
@@ -151,3 +161,158 @@ func TestGroups(t *testing.T) {
 	require.Empty(t, groupsAfterRemove)
 
 }
+
+func TestMigrateSourceAttribute(t *testing.T) {
+	ytClient := getTestYtsaurusClient(t)
+	yt := newYtsaurusWithClient(&YtsaurusConfig{}, ytClient, getDevelopmentLogger(), clock.RealClock{})
+
+	legacyOleg := map[string]any{"id": "fake-az-id-oleg"}
+	require.NoError(t, doCreateYtsaurusUser(context.Background(), ytClient, "oleg", map[string]any{"azure": legacyOleg}))
+	require.NoError(t, doCreateYtsaurusGroup(context.Background(), ytClient, "olegs", map[string]any{"azure": legacyOleg}))
+	require.NoError(t, doCreateYtsaurusUser(context.Background(), ytClient, "manual", nil))
+
+	cfg := SourceAttributeMigrationConfig{
+		LegacyAttributeName:   "azure",
+		RemoveLegacyAttribute: true,
+	}
+	// Dry-run changes nothing.
+	require.NoError(t, yt.MigrateSourceAttribute(cfg))
+	exists, err := ytClient.NodeExists(context.Background(), ypath.Path("//sys/users/oleg/@source"), nil)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	cfg.ApplyChanges = true
+	require.NoError(t, yt.MigrateSourceAttribute(cfg))
+	for _, path := range []string{"//sys/users/oleg", "//sys/groups/olegs"} {
+		var value map[string]any
+		require.NoError(t, ytClient.GetNode(context.Background(), ypath.Path(path+"/@source"), &value, nil))
+		require.Equal(t, legacyOleg, value)
+		exists, err = ytClient.NodeExists(context.Background(), ypath.Path(path+"/@azure"), nil)
+		require.NoError(t, err)
+		require.False(t, exists)
+	}
+	exists, err = ytClient.NodeExists(context.Background(), ypath.Path("//sys/users/manual/@source"), nil)
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestRecordAndRestoreMemberships(t *testing.T) {
+	ytClient := getTestYtsaurusClient(t)
+	yt := getYtsaurus(t, ytClient)
+	yt.membershipsStorePath = "//tmp/memberships"
+
+	const olegAzureID = "fake-az-id-oleg"
+	managedOleg := YtsaurusUser{
+		Username:  "oleg",
+		SourceRaw: map[string]any{"id": olegAzureID},
+	}
+	require.NoError(t, yt.CreateUser(managedOleg))
+	require.NoError(t, doCreateYtsaurusGroup(context.Background(), ytClient, "manual-olegs", nil))
+	require.NoError(t, doAddMemberYtsaurusGroup(context.Background(), ytClient, managedOleg.Username, "manual-olegs"))
+
+	require.NoError(t, yt.RecordMemberships(olegAzureID, managedOleg.Username))
+	require.NoError(t, yt.RemoveUser(managedOleg.Username))
+	require.Eventually(t, func() bool {
+		exists, err := ytClient.NodeExists(context.Background(), ypath.Path("//sys/users/oleg"), nil)
+		return err == nil && !exists
+	}, 3*time.Second, 300*time.Millisecond)
+
+	require.NoError(t, yt.CreateUser(managedOleg))
+	restored, err := yt.RestoreMemberships(olegAzureID, managedOleg.Username)
+	require.NoError(t, err)
+	require.Equal(t, 1, restored)
+
+	var members []string
+	require.NoError(t, ytClient.GetNode(context.Background(), ypath.Path("//sys/groups/manual-olegs/@members"), &members, nil))
+	require.Equal(t, []string{managedOleg.Username}, members)
+
+	exists, err := ytClient.NodeExists(context.Background(), ypath.Path("//tmp/memberships/"+olegAzureID), nil)
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestHomeDirectories(t *testing.T) {
+	ytClient := getTestYtsaurusClient(t)
+	ytsaurus := getYtsaurus(t, ytClient)
+	ytsaurus.homeDirectories = HomeDirectoriesConfig{
+		PathTemplate:        "//tmp/home/{username}",
+		Permissions:         []string{"read", "write"},
+		OnUserRemoval:       HomeDirectoryRemovalPolicyArchive,
+		ArchivePathTemplate: "//tmp/archive/home/{username}-{cycle_id}",
+	}
+	ctx := context.Background()
+
+	managedOleg := YtsaurusUser{
+		Username:  "oleg",
+		SourceRaw: map[string]any{"id": "fake-az-id-oleg"},
+	}
+	require.NoError(t, ytsaurus.CreateUser(managedOleg))
+	require.NoError(t, ytsaurus.CreateHomeDirectory(managedOleg.Username))
+
+	var acl []yt.ACE
+	require.NoError(t, ytClient.GetNode(ctx, ypath.Path("//tmp/home/oleg/@acl"), &acl, nil))
+	require.Len(t, acl, 1)
+	require.Equal(t, yt.ActionAllow, acl[0].Action)
+	require.Equal(t, []string{managedOleg.Username}, acl[0].Subjects)
+	require.Equal(t, []yt.Permission{yt.PermissionRead, yt.PermissionWrite}, acl[0].Permissions)
+
+	// Existing home directory (e.g. kept for the previous user with the same name) is reported and left as is.
+	require.NoError(t, ytClient.SetNode(ctx, ypath.Path("//tmp/home/oleg/@acl"), []yt.ACE{{
+		Action:      yt.ActionAllow,
+		Subjects:    []string{"previous-owners"},
+		Permissions: []yt.Permission{yt.PermissionRead},
+	}}, nil))
+	require.ErrorContains(t, ytsaurus.CreateHomeDirectory(managedOleg.Username), "already exists")
+	require.NoError(t, ytClient.GetNode(ctx, ypath.Path("//tmp/home/oleg/@acl"), &acl, nil))
+	require.Equal(t, []string{"previous-owners"}, acl[0].Subjects)
+
+	require.NoError(t, ytsaurus.ArchiveHomeDirectory(managedOleg.Username, "cycle1"))
+	exists, err := ytClient.NodeExists(ctx, ypath.Path("//tmp/home/oleg"), nil)
+	require.NoError(t, err)
+	require.False(t, exists)
+	exists, err = ytClient.NodeExists(ctx, ypath.Path("//tmp/archive/home/oleg-cycle1"), nil)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	// Missing home directory is skipped.
+	require.NoError(t, ytsaurus.ArchiveHomeDirectory(managedOleg.Username, "cycle2"))
+}
+
+func TestValidateHomeDirectoriesConfig(t *testing.T) {
+	require.NoError(t, validateHomeDirectoriesConfig(&HomeDirectoriesConfig{}))
+	require.NoError(t, validateHomeDirectoriesConfig(&HomeDirectoriesConfig{PathTemplate: "//home/{username}"}))
+	require.Error(t, validateHomeDirectoriesConfig(&HomeDirectoriesConfig{PathTemplate: "//home/oleg"}))
+	require.Error(t, validateHomeDirectoriesConfig(&HomeDirectoriesConfig{
+		PathTemplate:  "//home/{username}",
+		OnUserRemoval: HomeDirectoryRemovalPolicyArchive,
+	}))
+	require.Error(t, validateHomeDirectoriesConfig(&HomeDirectoriesConfig{
+		PathTemplate:  "//home/{username}",
+		OnUserRemoval: "remove",
+	}))
+}
+
+func TestNewYtsaurusClientTransports(t *testing.T) {
+	require.NoError(t, validateYtsaurusTransport(""))
+	require.NoError(t, validateYtsaurusTransport(YtsaurusTransportRPC))
+	require.Error(t, validateYtsaurusTransport("grpc"))
+
+	for _, transport := range []YtsaurusTransport{"", YtsaurusTransportHTTP, YtsaurusTransportRPC} {
+		client, err := newYtsaurusClient(transport, "localhost:8000", "localhost:9013", "token")
+		require.NoError(t, err)
+		client.Stop()
+	}
+}
+
+func TestGetUsersUnexpectedAttributeType(t *testing.T) {
+	ytClient := getTestYtsaurusClient(t)
+	ytsaurus := getYtsaurus(t, ytClient)
+	ctx := context.Background()
+
+	require.NoError(t, doCreateYtsaurusUser(ctx, ytClient, "alice", map[string]any{
+		"azure":        map[string]any{"id": "fake-az-id-alice"},
+		"banned_since": 42,
+	}))
+	_, err := ytsaurus.GetUsers()
+	require.ErrorContains(t, err, "unexpected type")
+}